@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"path"
+	"strconv"
+)
+
+var errStale = errors.New("generated code is out of date")
+
+// staleItem is an interface or method whose implementation is out of date.
+type staleItem struct {
+	// Name is the qualified name of the interface or method in the API.
+	Name     string
+	Location string
+	Reason   string
+}
+
+// staleItems returns every interface and method whose implementation a run
+// would create or change, and every method whose signature drifted from the
+// API, whether or not drift is rewritten.
+func staleItems(repositories []*RepositoryImpl) (items []staleItem) {
+	for _, repository := range repositories {
+		location := path.Join(repository.ImplPackagePath, repository.ImplFilename)
+		if repository.IsNew {
+			items = append(items, staleItem{
+				Name:     repository.QualifiedName(),
+				Location: location,
+				Reason:   "missing implementation",
+			})
+		}
+		for _, method := range repository.NewMethods() {
+			items = append(items, staleItem{
+				Name:     repository.QualifiedName() + "." + method.Ident,
+				Location: location,
+				Reason:   "missing method",
+			})
+		}
+		for _, drift := range repository.Drifted {
+			items = append(items, staleItem{
+				Name:     repository.QualifiedName() + "." + drift.Method.Ident,
+				Location: path.Join(repository.ImplPackagePath, drift.Impl.Filename) + ":" + strconv.Itoa(drift.Impl.Line),
+				Reason:   "signature drifted from the API",
+			})
+		}
+		if cli.Orphans != orphansReport {
+			for _, orphan := range repository.Orphaned {
+				if cli.Orphans == orphansDeprecate && orphan.deprecated() {
+					continue
+				}
+				items = append(items, staleItem{
+					Name:     repository.QualifiedName() + "." + orphan.Ident,
+					Location: path.Join(repository.ImplPackagePath, orphan.Filename) + ":" + strconv.Itoa(orphan.Line),
+					Reason:   "removed from the API",
+				})
+			}
+		}
+	}
+	return items
+}
+
+// reportStale logs every stale interface and method, and every generated file
+// that differs from the one on disk. It returns errStale if anything is stale.
+func reportStale(items []staleItem, changedFiles []string) error {
+	for _, item := range items {
+		slog.Error(
+			"Implementation is out of date",
+			slog.String("name", item.Name),
+			slog.String("location", item.Location),
+			slog.String("reason", item.Reason),
+		)
+	}
+	for _, file := range changedFiles {
+		slog.Error("Generated file is out of date", slog.String("path", file))
+	}
+	if len(items) > 0 || len(changedFiles) > 0 {
+		return errStale
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaleItems(t *testing.T) {
+	repository := Repository{
+		Package:     "users",
+		PackagePath: "api/users",
+		Ident:       "UserRepository",
+		Profile:     newProfile("Repository"),
+		Methods: []*Method{
+			{Ident: "Get"},
+			{Ident: "Delete"},
+		},
+	}
+	for _, test := range []struct {
+		name    string
+		drift   string
+		orphans string
+		impl    *RepositoryImpl
+		expect  []staleItem
+	}{
+		{
+			"up to date",
+			driftRewrite,
+			orphansReport,
+			&RepositoryImpl{
+				Repository:      repository,
+				ImplPackagePath: "internal/users",
+				ImplFilename:    "user_repository_impl.go",
+				ImplMethods:     []string{"Get", "Delete"},
+			},
+			nil,
+		},
+		{
+			"missing implementation",
+			driftRewrite,
+			orphansReport,
+			&RepositoryImpl{
+				Repository:      repository,
+				IsNew:           true,
+				ImplPackagePath: "internal/users",
+				ImplFilename:    "user_repository_impl.go",
+			},
+			[]staleItem{
+				{"users.UserRepository", "internal/users/user_repository_impl.go", "missing implementation"},
+				{"users.UserRepository.Get", "internal/users/user_repository_impl.go", "missing method"},
+				{"users.UserRepository.Delete", "internal/users/user_repository_impl.go", "missing method"},
+			},
+		},
+		{
+			"drifted and orphaned methods",
+			driftRewrite,
+			orphansDeprecate,
+			&RepositoryImpl{
+				Repository:      repository,
+				ImplPackagePath: "internal/users",
+				ImplFilename:    "user_repository_impl.go",
+				ImplMethods:     []string{"Get", "Delete"},
+				Drifted: []*MethodDrift{{
+					Method: repository.Methods[0],
+					Impl:   &ImplMethod{Ident: "Get", Filename: "get.go", Line: 10},
+				}},
+				Orphaned: []*ImplMethod{
+					{Ident: "List", Filename: "list.go", Line: 3},
+					{Ident: "Count", Filename: "list.go", Line: 8, Doc: []string{"// Deprecated: removed."}},
+				},
+			},
+			[]staleItem{
+				{"users.UserRepository.Get", "internal/users/get.go:10", "signature drifted from the API"},
+				{"users.UserRepository.List", "internal/users/list.go:3", "removed from the API"},
+			},
+		},
+		{
+			"reported drift is stale, reported orphans are not",
+			driftReport,
+			orphansReport,
+			&RepositoryImpl{
+				Repository:      repository,
+				ImplPackagePath: "internal/users",
+				ImplFilename:    "user_repository_impl.go",
+				ImplMethods:     []string{"Get", "Delete"},
+				Drifted: []*MethodDrift{{
+					Method: repository.Methods[0],
+					Impl:   &ImplMethod{Ident: "Get", Filename: "get.go", Line: 10},
+				}},
+				Orphaned: []*ImplMethod{{Ident: "List", Filename: "list.go", Line: 3}},
+			},
+			[]staleItem{
+				{"users.UserRepository.Get", "internal/users/get.go:10", "signature drifted from the API"},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			drift, orphans := cli.Drift, cli.Orphans
+			defer func() { cli.Drift, cli.Orphans = drift, orphans }()
+			cli.Drift, cli.Orphans = test.drift, test.orphans
+
+			got := staleItems([]*RepositoryImpl{test.impl})
+			require.Equal(test.expect, got)
+			if test.expect == nil {
+				require.NoError(reportStale(got, nil))
+			} else {
+				require.ErrorIs(reportStale(got, nil), errStale)
+			}
+		})
+	}
+	require.ErrorIs(t, reportStale(nil, []string{"internal/repositories.go"}), errStale)
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFilename is the name of the project configuration file, looked up in
+// the root directory and its parents.
+const configFilename = "implgen.yaml"
+
+// Config is the project configuration read from implgen.yaml. Paths are
+// relative to the directory containing the file.
+type Config struct {
+	// API and Impl are the directories of API definitions and implementations.
+	API  string `yaml:"api"`
+	Impl string `yaml:"impl"`
+	// PackageSuffix is appended to the name of an API package to name its
+	// implementation package, e.g. users -> usersimpl.
+	PackageSuffix string `yaml:"packageSuffix"`
+	// StubFile names the file in Impl aggregating every implementation.
+	StubFile string `yaml:"stubFile"`
+	// DI is the dependency injection framework implementations are provided with.
+	DI string `yaml:"di"`
+	// Profiles are the interface suffixes to implement and how their
+	// implementations are named, see ProfileConfig.
+	Profiles []ProfileConfig `yaml:"profiles"`
+	// ContextTypes and ErrorTypes are types, qualified with their import path,
+	// instrumented like context.Context and error in generated methods, e.g.
+	// example.com/app.Context.
+	ContextTypes []string `yaml:"contextTypes"`
+	ErrorTypes   []string `yaml:"errorTypes"`
+	// Lifecycle names the API methods called by fx lifecycle hooks.
+	Lifecycle     LifecycleConfig `yaml:"lifecycle"`
+	PackageConfig `yaml:",inline"`
+	// Packages overrides the configuration of API packages, keyed by their
+	// path relative to API.
+	Packages map[string]PackageConfig `yaml:"packages"`
+}
+
+// LifecycleConfig names the methods called by the OnStart and OnStop hooks
+// registered by the constructors of fx implementations. The first method
+// declared by an API interface is called.
+type LifecycleConfig struct {
+	OnStart []string `yaml:"onStart"`
+	OnStop  []string `yaml:"onStop"`
+}
+
+// PackageConfig is the configuration that can be overridden per API package.
+// Empty fields inherit the project configuration.
+type PackageConfig struct {
+	// Errors is how returned errors are wrapped.
+	Errors string `yaml:"errors"`
+	// Tracing is how spans are started for methods accepting a context.
+	Tracing string `yaml:"tracing"`
+	// Mocks is the tool mocks of the API are generated with.
+	Mocks string `yaml:"mocks"`
+}
+
+const (
+	diFx       = "fx"
+	diFxModule = "fx-module"
+	diWire     = "wire"
+	diNone     = "none"
+
+	errorsEris      = "eris"
+	errorsFmt       = "fmt"
+	errorsPkgErrors = "pkgerrors"
+	errorsCockroach = "cockroach"
+	errorsNone      = "none"
+
+	tracingOtel       = "otel"
+	tracingOpenCensus = "opencensus"
+	tracingDatadog    = "datadog"
+	tracingNone       = "none"
+
+	mocksMoq  = "moq"
+	mocksNone = "none"
+)
+
+var (
+	supportedDI      = []string{diFx, diFxModule, diWire, diNone}
+	supportedErrors  = []string{errorsEris, errorsFmt, errorsPkgErrors, errorsCockroach, errorsNone}
+	supportedTracing = []string{tracingOtel, tracingOpenCensus, tracingDatadog, tracingNone}
+	supportedMocks   = []string{mocksMoq, mocksNone}
+)
+
+func defaultConfig() *Config {
+	return &Config{
+		API:           "api",
+		Impl:          "internal",
+		PackageSuffix: "impl",
+		StubFile:      "repositories.go",
+		DI:            diFx,
+		Profiles:      []ProfileConfig{{Suffix: defaultProfile.Suffix}},
+		Lifecycle: LifecycleConfig{
+			OnStart: []string{"Start"},
+			OnStop:  []string{"Stop", "Close"},
+		},
+		PackageConfig: PackageConfig{
+			Errors:  errorsEris,
+			Tracing: tracingOtel,
+			Mocks:   mocksMoq,
+		},
+	}
+}
+
+var config = defaultConfig()
+
+// findConfig returns the path of the configuration file in root or the
+// closest parent directory containing one, or "" if there is none.
+func findConfig(root string) (string, error) {
+	dir, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	for {
+		configPath := filepath.Join(dir, configFilename)
+		if _, err := os.Stat(configPath); err == nil {
+			return configPath, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// loadConfig loads the configuration file applying to root, falling back to
+// the default configuration if there is none.
+func loadConfig(root string) (*Config, error) {
+	configPath, err := findConfig(root)
+	if err != nil {
+		return nil, err
+	}
+	if configPath == "" {
+		return defaultConfig(), nil
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseConfig(data, filepath.Dir(configPath), root)
+	if err != nil {
+		return nil, &ConfigError{Path: configPath, Err: err}
+	}
+	return cfg, nil
+}
+
+// parseConfig parses and validates a configuration file located in configDir,
+// making its paths relative to root.
+func parseConfig(data []byte, configDir, root string) (*Config, error) {
+	cfg := defaultConfig()
+	// Directories are cleared so that only those set in the file are resolved
+	// relative to it, the defaults being relative to the root.
+	defaults := *cfg
+	cfg.API, cfg.Impl = "", ""
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	for _, dir := range []struct {
+		field    string
+		path     *string
+		fallback string
+	}{
+		{"api", &cfg.API, defaults.API},
+		{"impl", &cfg.Impl, defaults.Impl},
+	} {
+		if *dir.path == "" {
+			*dir.path = dir.fallback
+			continue
+		}
+		rel, err := relativeToRoot(configDir, root, *dir.path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dir.field, err)
+		}
+		*dir.path = rel
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// relativeToRoot returns p, relative to configDir, as a slash-separated path
+// relative to root.
+func relativeToRoot(configDir, root, p string) (string, error) {
+	if filepath.IsAbs(p) {
+		return "", fmt.Errorf("%q must be relative to the configuration file", p)
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absRoot, filepath.Join(configDir, filepath.FromSlash(p)))
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%q is outside of the root %s", p, root)
+	}
+	return rel, nil
+}
+
+func (c *Config) validate() error {
+	if c.PackageSuffix == "" || !token.IsIdentifier("x"+c.PackageSuffix) {
+		return fmt.Errorf("packageSuffix: %q can't be used in a package name", c.PackageSuffix)
+	}
+	if path.Base(c.StubFile) != c.StubFile || path.Ext(c.StubFile) != ".go" {
+		return fmt.Errorf("stubFile: %q must be the name of a .go file", c.StubFile)
+	}
+	if err := validateChoice("di", c.DI, supportedDI); err != nil {
+		return err
+	}
+	for _, profile := range c.Profiles {
+		if _, err := profile.profile(); err != nil {
+			return fmt.Errorf("profiles: %w", err)
+		}
+	}
+	for _, types := range []struct {
+		field string
+		names []string
+	}{
+		{"contextTypes", c.ContextTypes},
+		{"errorTypes", c.ErrorTypes},
+	} {
+		for _, name := range types.names {
+			if _, _, ok := splitQualifiedType(name); !ok {
+				return fmt.Errorf("%s: %q must be a type name qualified with its import path, e.g. example.com/app.Context", types.field, name)
+			}
+		}
+	}
+	for _, hook := range []struct {
+		field   string
+		methods []string
+	}{
+		{"lifecycle.onStart", c.Lifecycle.OnStart},
+		{"lifecycle.onStop", c.Lifecycle.OnStop},
+	} {
+		for _, method := range hook.methods {
+			if !token.IsExported(method) || !token.IsIdentifier(method) {
+				return fmt.Errorf("%s: %q must be the name of an exported method", hook.field, method)
+			}
+		}
+	}
+	if err := c.PackageConfig.validate(""); err != nil {
+		return err
+	}
+	for _, packagePath := range sortedKeys(c.Packages) {
+		if path.Clean(packagePath) != packagePath || path.IsAbs(packagePath) || strings.HasPrefix(packagePath, "../") {
+			return fmt.Errorf("packages: %q must be a clean path relative to the API directory", packagePath)
+		}
+		if err := c.Packages[packagePath].validate("packages." + packagePath + "."); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c PackageConfig) validate(prefix string) error {
+	for _, choice := range []struct {
+		field     string
+		value     string
+		supported []string
+	}{
+		{"errors", c.Errors, supportedErrors},
+		{"tracing", c.Tracing, supportedTracing},
+		{"mocks", c.Mocks, supportedMocks},
+	} {
+		if choice.value == "" && prefix != "" {
+			continue
+		}
+		if err := validateChoice(prefix+choice.field, choice.value, choice.supported); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func validateChoice(field, value string, supported []string) error {
+	if slices.Contains(supported, value) {
+		return nil
+	}
+	return fmt.Errorf("%s: unsupported value %q, expected one of: %s", field, value, strings.Join(supported, ", "))
+}
+
+// checkPackages returns an error if the configuration overrides a package that
+// is not among the crawled API packages.
+func (c *Config) checkPackages(apiPackagePaths []string) error {
+	for _, packagePath := range sortedKeys(c.Packages) {
+		if !slices.Contains(apiPackagePaths, path.Join(cli.API, packagePath)) {
+			return fmt.Errorf("packages: no API package %q in %s", packagePath, cli.API)
+		}
+	}
+	return nil
+}
+
+// relativeToAPI returns the path of the API package at packagePath, relative
+// to the root, relative to the API directory.
+func relativeToAPI(packagePath string) string {
+	if path.Clean(packagePath) == path.Clean(cli.API) {
+		return "."
+	}
+	return strings.TrimPrefix(path.Clean(packagePath), path.Clean(cli.API)+"/")
+}
+
+// forPackage returns the configuration of the API package at packagePath,
+// relative to the root.
+func (c *Config) forPackage(packagePath string) PackageConfig {
+	cfg := c.PackageConfig
+	override, ok := c.Packages[relativeToAPI(packagePath)]
+	if !ok {
+		return cfg
+	}
+	if override.Errors != "" {
+		cfg.Errors = override.Errors
+	}
+	if override.Tracing != "" {
+		cfg.Tracing = override.Tracing
+	}
+	if override.Mocks != "" {
+		cfg.Mocks = override.Mocks
+	}
+	return cfg
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	withDefaults := func(modify func(cfg *Config)) *Config {
+		cfg := defaultConfig()
+		modify(cfg)
+		return cfg
+	}
+	for _, test := range []struct {
+		name      string
+		src       string
+		configDir string
+		root      string
+		expect    *Config
+		expectErr string
+	}{
+		{
+			"empty file",
+			``,
+			"/repo",
+			"/repo",
+			defaultConfig(),
+			"",
+		},
+		{
+			"every option",
+			`
+api: definitions
+impl: pkg/impl
+packageSuffix: service
+stubFile: services.go
+di: fx
+errors: eris
+tracing: none
+mocks: none
+profiles:
+  - Repository
+  - Store=Store$
+  - suffix: Gateway
+    implSuffix: Client
+    options: Module
+    registry: Clients
+    templates: gateway
+contextTypes: [example.com/app.Context]
+errorTypes: [example.com/app/errs.Error]
+lifecycle:
+  onStart: [Open]
+  onStop: []
+packages:
+  users:
+    tracing: otel
+  v1/movies:
+    mocks: moq
+`,
+			"/repo",
+			"/repo",
+			withDefaults(func(cfg *Config) {
+				cfg.API = "definitions"
+				cfg.Impl = "pkg/impl"
+				cfg.PackageSuffix = "service"
+				cfg.StubFile = "services.go"
+				cfg.Tracing = tracingNone
+				cfg.Mocks = mocksNone
+				cfg.Profiles = []ProfileConfig{
+					{Suffix: "Repository"},
+					{Suffix: "Store", Pattern: "Store$"},
+					{Suffix: "Gateway", ImplSuffix: "Client", Options: "Module", Registry: "Clients", Templates: "gateway"},
+				}
+				cfg.ContextTypes = []string{"example.com/app.Context"}
+				cfg.ErrorTypes = []string{"example.com/app/errs.Error"}
+				cfg.Lifecycle = LifecycleConfig{OnStart: []string{"Open"}, OnStop: []string{}}
+				cfg.Packages = map[string]PackageConfig{
+					"users":     {Tracing: tracingOtel},
+					"v1/movies": {Mocks: mocksMoq},
+				}
+			}),
+			"",
+		},
+		{
+			"directories are relative to the configuration file",
+			`
+api: svc/api
+`,
+			"/repo",
+			"/repo/svc",
+			withDefaults(func(cfg *Config) {
+				cfg.API = "api"
+			}),
+			"",
+		},
+		{
+			"directory outside of root",
+			`
+impl: internal
+`,
+			"/repo",
+			"/repo/svc",
+			nil,
+			`impl: "internal" is outside of the root /repo/svc`,
+		},
+		{
+			"unknown field",
+			`
+tracer: otel
+`,
+			"/repo",
+			"/repo",
+			nil,
+			"yaml: unmarshal errors:\n  line 2: field tracer not found in type main.Config",
+		},
+		{
+			"unsupported value",
+			`
+tracing: zipkin
+`,
+			"/repo",
+			"/repo",
+			nil,
+			`tracing: unsupported value "zipkin", expected one of: otel, opencensus, datadog, none`,
+		},
+		{
+			"unsupported package value",
+			`
+packages:
+  users:
+    mocks: mockery
+`,
+			"/repo",
+			"/repo",
+			nil,
+			`packages.users.mocks: unsupported value "mockery", expected one of: moq, none`,
+		},
+		{
+			"invalid package path",
+			`
+packages:
+  ./users:
+    mocks: none
+`,
+			"/repo",
+			"/repo",
+			nil,
+			`packages: "./users" must be a clean path relative to the API directory`,
+		},
+		{
+			"invalid stub file",
+			`
+stubFile: stub/repositories.go
+`,
+			"/repo",
+			"/repo",
+			nil,
+			`stubFile: "stub/repositories.go" must be the name of a .go file`,
+		},
+		{
+			"invalid package suffix",
+			`
+packageSuffix: "-impl"
+`,
+			"/repo",
+			"/repo",
+			nil,
+			`packageSuffix: "-impl" can't be used in a package name`,
+		},
+		{
+			"unqualified context type",
+			`
+contextTypes: [Context]
+`,
+			"/repo",
+			"/repo",
+			nil,
+			`contextTypes: "Context" must be a type name qualified with its import path, e.g. example.com/app.Context`,
+		},
+		{
+			"unexported lifecycle method",
+			`
+lifecycle:
+  onStop: [close]
+`,
+			"/repo",
+			"/repo",
+			nil,
+			`lifecycle.onStop: "close" must be the name of an exported method`,
+		},
+		{
+			"invalid profile",
+			`
+profiles: ["=Store$"]
+`,
+			"/repo",
+			"/repo",
+			nil,
+			`profiles: invalid profile "=Store$": missing suffix`,
+		},
+		{
+			"invalid profile name",
+			`
+profiles:
+  - suffix: Gateway
+    registry: clients
+`,
+			"/repo",
+			"/repo",
+			nil,
+			`profiles: invalid profile "Gateway": registry: "clients" can't be used`,
+		},
+		{
+			"unknown profile field",
+			`
+profiles:
+  - suffix: Gateway
+    impl: Client
+`,
+			"/repo",
+			"/repo",
+			nil,
+			"yaml: unmarshal errors:\n  line 4: field impl not found in type main.profileFields",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			cfg, err := parseConfig([]byte(test.src), test.configDir, test.root)
+			if test.expectErr != "" {
+				require.EqualError(err, test.expectErr)
+				return
+			}
+			require.NoError(err)
+			require.Equal(test.expect, cfg)
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	require := require.New(t)
+	repo := t.TempDir()
+	root := filepath.Join(repo, "svc")
+	require.NoError(os.MkdirAll(root, 0755))
+
+	cfg, err := loadConfig(root)
+	require.NoError(err)
+	require.Equal(defaultConfig(), cfg)
+
+	require.NoError(os.WriteFile(filepath.Join(repo, configFilename), []byte("impl: svc/pkg\n"), 0644))
+	cfg, err = loadConfig(root)
+	require.NoError(err)
+	require.Equal("pkg", cfg.Impl)
+	require.Equal("api", cfg.API)
+
+	require.NoError(os.WriteFile(filepath.Join(repo, configFilename), []byte("di: dig\n"), 0644))
+	_, err = loadConfig(root)
+	var configErr *ConfigError
+	require.ErrorAs(err, &configErr)
+	require.Equal(filepath.Join(repo, configFilename), configErr.Path)
+}
+
+func TestConfigForPackage(t *testing.T) {
+	require := require.New(t)
+	api := cli.API
+	defer func() { cli.API = api }()
+	cli.API = "api"
+
+	cfg := defaultConfig()
+	cfg.Packages = map[string]PackageConfig{
+		"users":     {Tracing: tracingNone},
+		"v1/movies": {Mocks: mocksNone},
+	}
+	require.Equal(PackageConfig{Errors: errorsEris, Tracing: tracingNone, Mocks: mocksMoq}, cfg.forPackage("api/users"))
+	require.Equal(PackageConfig{Errors: errorsEris, Tracing: tracingOtel, Mocks: mocksNone}, cfg.forPackage("api/v1/movies"))
+	require.Equal(cfg.PackageConfig, cfg.forPackage("api/v1"))
+
+	require.NoError(cfg.checkPackages([]string{"api/users", "api/v1/movies"}))
+	require.EqualError(cfg.checkPackages([]string{"api/users"}), `packages: no API package "v1/movies" in api`)
+
+	defer func(cfg *Config) { config = cfg }(config)
+	config = cfg
+	repository := Repository{PackagePath: "api/users", Ident: "UserRepository"}
+	method := Method{Ident: "Get", Params: Params{{Ident: "ctx", Type: "context.Context"}}}
+	require.False(repository.Traces(method))
+	repository.PackagePath = "api/movies"
+	require.True(repository.Traces(method))
+}
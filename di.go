@@ -0,0 +1,273 @@
+package main
+
+// diFramework is a strategy providing implementations with their dependencies.
+type diFramework struct {
+	// Impl is the template declaring the dependencies and constructor of a
+	// new implementation, and Stub the template aggregating every
+	// implementation. Both are overridden by the templates of the default
+	// template set, if any.
+	Impl string
+	Stub string
+	// Registry returns the name of the declaration aggregating the
+	// implementations of a profile in the stub file.
+	Registry func(profile *Profile) string
+	// Imports are the imports required by Impl and Stub.
+	Imports []Import
+	// StubImportsAPI reports whether Stub refers to the API packages.
+	StubImportsAPI bool
+}
+
+var diFrameworks = map[string]diFramework{
+	diFx: {
+		Impl: generateRepositoryImplTemplate,
+		Stub: repositoryStubFileTemplate,
+		Registry: func(profile *Profile) string {
+			return profile.Registry
+		},
+		Imports: []Import{{Path: "go.uber.org/fx"}},
+	},
+	diFxModule: {
+		Impl: generateFxModuleRepositoryImplTemplate,
+		Stub: fxModuleRepositoryStubFileTemplate,
+		Registry: func(profile *Profile) string {
+			return profile.Registry
+		},
+		Imports: []Import{{Path: "go.uber.org/fx"}},
+	},
+	diWire: {
+		Impl: generateWireRepositoryImplTemplate,
+		Stub: wireRepositoryStubFileTemplate,
+		Registry: func(profile *Profile) string {
+			return profile.ProviderSet
+		},
+		Imports: []Import{{Path: "github.com/google/wire"}},
+	},
+	diNone: {
+		Impl: generatePlainRepositoryImplTemplate,
+		Stub: plainRepositoryStubFileTemplate,
+		Registry: func(profile *Profile) string {
+			return profile.Registry
+		},
+		StubImportsAPI: true,
+	},
+}
+
+func (c *Config) diFramework() diFramework {
+	return diFrameworks[c.DI]
+}
+
+// ProviderSetName returns the name of the Wire provider set of the implementation.
+func (r Repository) ProviderSetName() string {
+	return r.QualifyString(r.profile().ProviderSet)
+}
+
+// generateFxModuleRepositoryImplTemplate provides the concrete implementation,
+// annotated as the API interface, so that it can be decorated. The dependencies
+// are held in a field rather than embedded, as fx can't provide a struct
+// embedding fx.In.
+const generateFxModuleRepositoryImplTemplate = `
+type {{ .Repository.DependenciesName }} struct {
+	fx.In
+	// Add dependencies here
+}
+
+{{ if .Repository.TypeParams -}}
+func {{ .Repository.OptionsName }}{{ .Repository.TypeParamsDecl }}() fx.Option {
+	return fx.Options(
+		fx.Provide(
+			fx.Annotate(
+				{{ .Repository.ConstructorName }}{{ .Repository.TypeArgs }},
+				fx.As(fx.Self()),
+				fx.As(new({{ .Repository.Package }}.{{ .Repository.Ident }}{{ .Repository.TypeArgs }})),
+			),
+		),
+	)
+}
+{{- else -}}
+var {{ .Repository.OptionsName }} = fx.Options(
+	fx.Provide(
+		fx.Annotate(
+			{{ .Repository.ConstructorName }},
+			fx.As(fx.Self()),
+			fx.As(new({{ .Repository.Package }}.{{ .Repository.Ident }})),
+		),
+	),
+)
+{{- end }}
+
+func {{ .Repository.ConstructorName }}{{ .Repository.TypeParamsDecl }}({{ if .Repository.HasLifecycle }}lc fx.Lifecycle, {{ end }}deps {{ .Repository.DependenciesName }}) *{{ .Repository.ImplName }}{{ .Repository.TypeArgs }} {
+{{- if .Repository.HasLifecycle }}
+	impl := &{{ .Repository.ImplName }}{{ .Repository.TypeArgs }}{
+		deps: deps,
+	}
+	lc.Append(fx.Hook{
+		{{- with .Repository.OnStartHook }}
+		OnStart: {{ . }},
+		{{- end }}
+		{{- with .Repository.OnStopHook }}
+		OnStop: {{ . }},
+		{{- end }}
+	})
+	return impl
+{{- else }}
+	return &{{ .Repository.ImplName }}{{ .Repository.TypeArgs }}{
+		deps: deps,
+	}
+{{- end }}
+}
+
+{{ with .Repository.Doc -}}
+// {{ $.Repository.ImplName }} implements {{ $.Repository.QualifiedName }}.
+//
+{{ range . -}}
+{{ . }}
+{{ end -}}
+{{ end -}}
+type {{ .Repository.ImplName }}{{ .Repository.TypeParamsDecl }} struct {
+	deps {{ .Repository.DependenciesName }}
+}
+`
+
+// fxModuleRepositoryStubFileTemplate groups the options of each implementation
+// package in a named module.
+const fxModuleRepositoryStubFileTemplate = `
+// DO NOT MODIFY
+// This file will be automatically regenerated based on the API.
+package {{ .Package }}
+{{ range .MockDirectives -}}
+//go:generate moq -out={{ .Dst }} -pkg={{ .ImplPackage }} -rm -skip-ensure {{ .Src }} {{ range .Repositories }}{{.}} {{ end }}
+{{ end -}}
+
+{{ range .Imports }}
+import {{ .Name }} "{{ .Path }}"
+{{- end }}
+{{ range .Registries }}
+var {{ .Name }} = fx.Options(
+{{ range .Modules -}}
+  fx.Module({{ printf "%q" .Name }},
+{{ range .Repositories -}}
+    {{ .ImplPackage }}.{{ .OptionsName }},
+{{ end -}}
+  ),
+{{ end -}}
+)
+{{ end -}}
+`
+
+// generateWireRepositoryImplTemplate declares a dependencies struct and a
+// constructor returning the concrete implementation, bound to the API interface
+// by a provider set which injects every field of the dependencies struct.
+// Generic implementations can't be part of a provider set until instantiated,
+// which is left to the caller.
+const generateWireRepositoryImplTemplate = `
+type {{ .Repository.DependenciesName }} struct {
+	// Add dependencies here
+}
+
+{{ if not .Repository.TypeParams -}}
+var {{ .Repository.ProviderSetName }} = wire.NewSet(
+	wire.Struct(new({{ .Repository.DependenciesName }}), "*"),
+	{{ .Repository.ConstructorName }},
+	wire.Bind(new({{ .Repository.Package }}.{{ .Repository.Ident }}), new(*{{ .Repository.ImplName }})),
+)
+
+{{ end -}}
+func {{ .Repository.ConstructorName }}{{ .Repository.TypeParamsDecl }}(deps {{ .Repository.DependenciesName }}) *{{ .Repository.ImplName }}{{ .Repository.TypeArgs }} {
+	return &{{ .Repository.ImplName }}{{ .Repository.TypeArgs }}{
+		{{ .Repository.DependenciesName }}: deps,
+	}
+}
+
+{{ with .Repository.Doc -}}
+// {{ $.Repository.ImplName }} implements {{ $.Repository.QualifiedName }}.
+//
+{{ range . -}}
+{{ . }}
+{{ end -}}
+{{ end -}}
+type {{ .Repository.ImplName }}{{ .Repository.TypeParamsDecl }} struct {
+	{{ .Repository.DependenciesName }}
+}
+`
+
+const wireRepositoryStubFileTemplate = `
+// DO NOT MODIFY
+// This file will be automatically regenerated based on the API.
+package {{ .Package }}
+{{ range .MockDirectives -}}
+//go:generate moq -out={{ .Dst }} -pkg={{ .ImplPackage }} -rm -skip-ensure {{ .Src }} {{ range .Repositories }}{{.}} {{ end }}
+{{ end -}}
+
+{{ range .Imports }}
+import {{ .Name }} "{{ .Path }}"
+{{- end }}
+{{ range .Registries }}
+var {{ .Name }} = wire.NewSet(
+{{ range .Repositories -}}
+  {{ .ImplPackage }}.{{ .ProviderSetName }},
+{{ end -}}
+)
+{{ end -}}
+`
+
+// generatePlainRepositoryImplTemplate declares a dependencies struct and a
+// constructor without depending on a framework.
+const generatePlainRepositoryImplTemplate = `
+type {{ .Repository.DependenciesName }} struct {
+	// Add dependencies here
+}
+
+func {{ .Repository.ConstructorName }}{{ .Repository.TypeParamsDecl }}(deps {{ .Repository.DependenciesName }}) {{ .Repository.Package }}.{{ .Repository.Ident }}{{ .Repository.TypeArgs }} {
+	return &{{ .Repository.ImplName }}{{ .Repository.TypeArgs }}{
+		{{ .Repository.DependenciesName }}: deps,
+	}
+}
+
+{{ with .Repository.Doc -}}
+// {{ $.Repository.ImplName }} implements {{ $.Repository.QualifiedName }}.
+//
+{{ range . -}}
+{{ . }}
+{{ end -}}
+{{ end -}}
+type {{ .Repository.ImplName }}{{ .Repository.TypeParamsDecl }} struct {
+	{{ .Repository.DependenciesName }}
+}
+`
+
+// plainRepositoryStubFileTemplate declares, for each registry, a struct holding
+// every repository and a constructor building them from a single dependencies
+// struct.
+const plainRepositoryStubFileTemplate = `
+// DO NOT MODIFY
+// This file will be automatically regenerated based on the API.
+package {{ .Package }}
+{{ range .MockDirectives -}}
+//go:generate moq -out={{ .Dst }} -pkg={{ .ImplPackage }} -rm -skip-ensure {{ .Src }} {{ range .Repositories }}{{.}} {{ end }}
+{{ end -}}
+
+{{ range .Imports }}
+import {{ .Name }} "{{ .Path }}"
+{{- end }}
+{{ range $registry := .Registries }}
+type {{ .Dependencies }} struct {
+{{ range .Repositories -}}
+  {{ $registry.FieldName . }} {{ .ImplPackage }}.{{ .DependenciesName }}
+{{ end -}}
+}
+
+type {{ .Name }} struct {
+{{ range .Repositories -}}
+  {{ $registry.FieldName . }} {{ .QualifiedName }}
+{{ end -}}
+}
+
+func New{{ .Name }}(deps {{ .Dependencies }}) *{{ .Name }} {
+	return &{{ .Name }}{
+{{ range .Repositories -}}
+  {{ $registry.FieldName . }}: {{ .ImplPackage }}.{{ .ConstructorName }}(deps.{{ $registry.FieldName . }}),
+{{ end -}}
+	}
+}
+{{ end -}}
+`
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDIFrameworks(t *testing.T) {
+	cli.Impl = "internal"
+	cli.API = "api"
+	repositories := func() []*RepositoryImpl {
+		return []*RepositoryImpl{
+			{
+				Repository: Repository{
+					Ident:       "UserRepository",
+					Package:     "users",
+					PackagePath: "api/users",
+				},
+				ImplPackage:     "usersimpl",
+				ImplPackagePath: "internal/users",
+			},
+			{
+				Repository: Repository{
+					Ident:       "MovieRepository",
+					Package:     "movies",
+					PackagePath: "api/movies",
+				},
+				ImplPackage:     "moviesimpl",
+				ImplPackagePath: "internal/movies",
+			},
+		}
+	}
+	for _, test := range []struct {
+		name       string
+		di         string
+		expectImpl string
+		expectStub string
+	}{
+		{
+			"fx module",
+			diFxModule,
+			`
+type UserDependencies struct {
+	fx.In
+	// Add dependencies here
+}
+
+var UserOptions = fx.Options(
+	fx.Provide(
+		fx.Annotate(
+			NewUserRepository,
+			fx.As(fx.Self()),
+			fx.As(new(users.UserRepository)),
+		),
+	),
+)
+
+func NewUserRepository(deps UserDependencies) *userRepositoryImpl {
+	return &userRepositoryImpl{
+		deps: deps,
+	}
+}
+
+type userRepositoryImpl struct {
+	deps UserDependencies
+}
+`,
+			`// DO NOT MODIFY
+// This file will be automatically regenerated based on the API.
+package internal
+
+import (
+	moviesimpl "example/internal/movies"
+	usersimpl "example/internal/users"
+
+	"go.uber.org/fx"
+)
+
+var Repositories = fx.Options(
+	fx.Module("movies",
+		moviesimpl.MovieOptions,
+	),
+	fx.Module("users",
+		usersimpl.UserOptions,
+	),
+)
+`,
+		},
+		{
+			"wire",
+			diWire,
+			`
+type UserDependencies struct {
+	// Add dependencies here
+}
+
+var UserProviderSet = wire.NewSet(
+	wire.Struct(new(UserDependencies), "*"),
+	NewUserRepository,
+	wire.Bind(new(users.UserRepository), new(*userRepositoryImpl)),
+)
+
+func NewUserRepository(deps UserDependencies) *userRepositoryImpl {
+	return &userRepositoryImpl{
+		UserDependencies: deps,
+	}
+}
+
+type userRepositoryImpl struct {
+	UserDependencies
+}
+`,
+			`// DO NOT MODIFY
+// This file will be automatically regenerated based on the API.
+package internal
+
+import (
+	moviesimpl "example/internal/movies"
+	usersimpl "example/internal/users"
+
+	"github.com/google/wire"
+)
+
+var ProviderSet = wire.NewSet(
+	moviesimpl.MovieProviderSet,
+	usersimpl.UserProviderSet,
+)
+`,
+		},
+		{
+			"none",
+			diNone,
+			`
+type UserDependencies struct {
+	// Add dependencies here
+}
+
+func NewUserRepository(deps UserDependencies) users.UserRepository {
+	return &userRepositoryImpl{
+		UserDependencies: deps,
+	}
+}
+
+type userRepositoryImpl struct {
+	UserDependencies
+}
+`,
+			`// DO NOT MODIFY
+// This file will be automatically regenerated based on the API.
+package internal
+
+import (
+	"example/api/movies"
+	"example/api/users"
+	moviesimpl "example/internal/movies"
+	usersimpl "example/internal/users"
+)
+
+type Dependencies struct {
+	MovieRepository moviesimpl.MovieDependencies
+	UserRepository  usersimpl.UserDependencies
+}
+
+type Repositories struct {
+	MovieRepository movies.MovieRepository
+	UserRepository  users.UserRepository
+}
+
+func NewRepositories(deps Dependencies) *Repositories {
+	return &Repositories{
+		MovieRepository: moviesimpl.NewMovieRepository(deps.MovieRepository),
+		UserRepository:  usersimpl.NewUserRepository(deps.UserRepository),
+	}
+}
+`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			defer func(original *Config) { config = original }(config)
+			config = defaultConfig()
+			config.DI = test.di
+			config.Mocks = mocksNone
+
+			impl, err := generateRepositoryImpl(repositories()[0].Repository)
+			require.NoError(err)
+			require.Equal(test.expectImpl, impl)
+
+			fsys := fstest.MapFS{
+				"go.mod": &fstest.MapFile{Data: []byte("module example"), Mode: 0644},
+			}
+			stub, err := generateRepositoryStubFile(fsys, "internal", repositories()...)
+			require.NoError(err)
+			require.Equal(test.expectStub, stub)
+		})
+	}
+}
+
+func TestWireGenericRepositoryImpl(t *testing.T) {
+	require := require.New(t)
+	defer func(original *Config) { config = original }(config)
+	config = defaultConfig()
+	config.DI = diWire
+
+	impl, err := generateRepositoryImpl(Repository{
+		Package:    "store",
+		Ident:      "StoreRepository",
+		TypeParams: Params{{Ident: "T", Type: "any"}},
+	})
+	require.NoError(err)
+	require.Equal(`
+type StoreDependencies struct {
+	// Add dependencies here
+}
+
+func NewStoreRepository[T any](deps StoreDependencies) *storeRepositoryImpl[T] {
+	return &storeRepositoryImpl[T]{
+		StoreDependencies: deps,
+	}
+}
+
+type storeRepositoryImpl[T any] struct {
+	StoreDependencies
+}
+`, impl)
+}
+
+func TestPlainStubAliasesAPIPackages(t *testing.T) {
+	require := require.New(t)
+	cli.Impl = "internal"
+	cli.API = "api"
+	defer func(original *Config) { config = original }(config)
+	config = defaultConfig()
+	config.DI = diNone
+	config.Mocks = mocksNone
+
+	fsys := fstest.MapFS{
+		"go.mod": &fstest.MapFile{Data: []byte("module example"), Mode: 0644},
+	}
+	stub, err := generateRepositoryStubFile(
+		fsys,
+		"internal",
+		&RepositoryImpl{
+			Repository: Repository{
+				Ident:       "UserRepository",
+				Package:     "users",
+				PackagePath: "api/users",
+			},
+			ImplPackage:     "usersimpl",
+			ImplPackagePath: "internal/users",
+		},
+		&RepositoryImpl{
+			Repository: Repository{
+				Ident:       "UserRepository",
+				Package:     "users",
+				PackagePath: "api/v1/users",
+			},
+			ImplPackage:     "usersimpl",
+			ImplPackagePath: "internal/v1/users",
+		},
+	)
+	require.NoError(err)
+	require.Equal(`// DO NOT MODIFY
+// This file will be automatically regenerated based on the API.
+package internal
+
+import (
+	apiusers "example/api/users"
+	v1users "example/api/v1/users"
+	internalusersimpl "example/internal/users"
+	v1usersimpl "example/internal/v1/users"
+)
+
+type Dependencies struct {
+	InternalusersimplUserRepository internalusersimpl.UserDependencies
+	V1usersimplUserRepository       v1usersimpl.UserDependencies
+}
+
+type Repositories struct {
+	InternalusersimplUserRepository apiusers.UserRepository
+	V1usersimplUserRepository       v1users.UserRepository
+}
+
+func NewRepositories(deps Dependencies) *Repositories {
+	return &Repositories{
+		InternalusersimplUserRepository: internalusersimpl.NewUserRepository(deps.InternalusersimplUserRepository),
+		V1usersimplUserRepository:       v1usersimpl.NewUserRepository(deps.V1usersimplUserRepository),
+	}
+}
+`, stub)
+}
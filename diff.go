@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// overlayFS is a WriteFS that reads through to base but keeps every write in
+// memory, recording the files written in order.
+type overlayFS struct {
+	base    fs.StatFS
+	mem     *memFS
+	written []string
+}
+
+func newOverlayFS(base fs.StatFS) *overlayFS {
+	return &overlayFS{base: base, mem: newMemFS()}
+}
+
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	if _, ok := o.mem.readFile(name); ok {
+		return o.mem.Open(name)
+	}
+	return o.base.Open(name)
+}
+
+func (o *overlayFS) Stat(name string) (fs.FileInfo, error) {
+	if _, ok := o.mem.readFile(name); ok {
+		return o.mem.Stat(name)
+	}
+	info, err := o.base.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.mem.Stat(name)
+	}
+	return info, err
+}
+
+func (o *overlayFS) MkdirAll(name string, perm fs.FileMode) error {
+	return o.mem.MkdirAll(name, perm)
+}
+
+func (o *overlayFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if _, ok := o.mem.readFile(name); !ok {
+		o.written = append(o.written, name)
+	}
+	return o.mem.WriteFile(name, data, perm)
+}
+
+const (
+	ansiReset = "\033[0m"
+	ansiBold  = "\033[1m"
+	ansiRed   = "\033[31m"
+	ansiGreen = "\033[32m"
+	ansiCyan  = "\033[36m"
+)
+
+// changedFiles returns the files written to the overlay whose contents differ
+// from the base file system, in the order they were written.
+func (o *overlayFS) changedFiles() (changed []string, err error) {
+	for _, name := range o.written {
+		oldSrc, err := readFile(o.base, name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		newSrc, _ := o.mem.readFile(name)
+		if err == nil && bytes.Equal(oldSrc, newSrc) {
+			continue
+		}
+		changed = append(changed, name)
+	}
+	return changed, nil
+}
+
+// writeDiffs writes a unified diff of every changed file in the overlay
+// against its contents in the base file system.
+func (o *overlayFS) writeDiffs(w io.Writer, changed []string, color bool) error {
+	for _, name := range changed {
+		oldSrc, err := readFile(o.base, name)
+		fromFile := "a/" + name
+		if errors.Is(err, fs.ErrNotExist) {
+			fromFile = "/dev/null"
+		} else if err != nil {
+			return err
+		}
+		newSrc, _ := o.mem.readFile(name)
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        splitLines(string(oldSrc)),
+			B:        splitLines(string(newSrc)),
+			FromFile: fromFile,
+			ToFile:   "b/" + name,
+			Context:  3,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to diff %s: %w", name, err)
+		}
+		if color {
+			diff = colorizeDiff(diff)
+		}
+		if _, err := io.WriteString(w, diff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitLines splits src into lines, keeping their line endings. Unlike
+// difflib.SplitLines, no empty line is added after a trailing newline.
+func splitLines(src string) []string {
+	lines := strings.SplitAfter(src, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func colorizeDiff(diff string) string {
+	lines := strings.SplitAfter(diff, "\n")
+	for i, line := range lines {
+		var color string
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			color = ansiBold
+		case strings.HasPrefix(line, "@@"):
+			color = ansiCyan
+		case strings.HasPrefix(line, "+"):
+			color = ansiGreen
+		case strings.HasPrefix(line, "-"):
+			color = ansiRed
+		default:
+			continue
+		}
+		text, newline := strings.CutSuffix(line, "\n")
+		lines[i] = color + text + ansiReset
+		if newline {
+			lines[i] += "\n"
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// isTerminal reports whether f is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayFS(t *testing.T) {
+	require := require.New(t)
+	base := fstest.MapFS{
+		"internal/users/users.go": &fstest.MapFile{Data: []byte("package users\n")},
+	}
+	fsys := newOverlayFS(base)
+
+	require.NoError(fsys.MkdirAll("internal/movies", 0755))
+	require.NoError(fsys.WriteFile("internal/movies/movies.go", []byte("package movies\n"), 0644))
+	require.NoError(fsys.WriteFile("internal/users/users.go", []byte("package usersimpl\n"), 0644))
+	require.NoError(fsys.WriteFile("internal/users/users.go", []byte("package users\n"), 0644))
+
+	data, err := readFile(fsys, "internal/movies/movies.go")
+	require.NoError(err)
+	require.Equal("package movies\n", string(data))
+	_, err = fsys.Stat("internal/movies")
+	require.NoError(err)
+	require.Equal([]string{"internal/movies/movies.go", "internal/users/users.go"}, fsys.written)
+	require.Len(base, 1, "base should not be written to")
+	require.Equal("package users\n", string(base["internal/users/users.go"].Data))
+}
+
+func TestWriteDiffs(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		base    map[string]string
+		writes  map[string]string
+		color   bool
+		expect  string
+		changed int
+	}{
+		{
+			"new file",
+			map[string]string{},
+			map[string]string{
+				"internal/users/users.go": "package users\n",
+			},
+			false,
+			`--- /dev/null
++++ b/internal/users/users.go
+@@ -0,0 +1 @@
++package users
+`,
+			1,
+		},
+		{
+			"changed file",
+			map[string]string{
+				"internal/users/users.go": "package users\n\nfunc A() {}\n",
+			},
+			map[string]string{
+				"internal/users/users.go": "package users\n\nfunc A() {}\n\nfunc B() {}\n",
+			},
+			false,
+			`--- a/internal/users/users.go
++++ b/internal/users/users.go
+@@ -1,3 +1,5 @@
+ package users
+ 
+ func A() {}
++
++func B() {}
+`,
+			1,
+		},
+		{
+			"unchanged file",
+			map[string]string{
+				"internal/repositories.go": "package internal\n",
+			},
+			map[string]string{
+				"internal/repositories.go": "package internal\n",
+			},
+			false,
+			``,
+			0,
+		},
+		{
+			"colorized",
+			map[string]string{
+				"users.go": "package users\n",
+			},
+			map[string]string{
+				"users.go": "package usersimpl\n",
+			},
+			true,
+			ansiBold + "--- a/users.go" + ansiReset + "\n" +
+				ansiBold + "+++ b/users.go" + ansiReset + "\n" +
+				ansiCyan + "@@ -1 +1 @@" + ansiReset + "\n" +
+				ansiRed + "-package users" + ansiReset + "\n" +
+				ansiGreen + "+package usersimpl" + ansiReset + "\n",
+			1,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			base := fstest.MapFS{}
+			for name, content := range test.base {
+				base[name] = &fstest.MapFile{Data: []byte(content), Mode: 0644}
+			}
+			fsys := newOverlayFS(base)
+			for name, content := range test.writes {
+				require.NoError(fsys.WriteFile(name, []byte(content), 0644))
+			}
+			changed, err := fsys.changedFiles()
+			require.NoError(err)
+			require.Len(changed, test.changed)
+			var out strings.Builder
+			require.NoError(fsys.writeDiffs(&out, changed, test.color))
+			require.Equal(test.expect, out.String())
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const directivePrefix = "//implgen:"
+
+// Directives are the //implgen:key[=value] comments attached to an API
+// interface, method or parameter, mapping each key to its (possibly empty)
+// value.
+//
+// Supported directives are:
+//
+//	//implgen:skip           Don't generate an implementation, or a method which
+//	                         is then expected to be provided by hand.
+//	//implgen:notrace        Don't start a span in the generated methods.
+//	//implgen:impl=NAME      Prefix the implementation's declarations with NAME.
+//	//implgen:file=FILENAME  Generate a new implementation in FILENAME.
+//	//implgen:redact         On a method, don't record any parameter or result
+//	                         on its span. On a parameter or result, don't
+//	                         record it.
+//	//implgen:redact=A,B     On a method, don't record the parameters or
+//	                         results named A and B on its span.
+type Directives map[string]string
+
+// Has reports whether the directive key is set.
+func (d Directives) Has(key string) bool {
+	_, ok := d[key]
+	return ok
+}
+
+// Get returns the value of the directive key, or "" if it isn't set.
+func (d Directives) Get(key string) string {
+	return d[key]
+}
+
+// parseDirectives extracts the directives from comment lines, returning the
+// remaining lines as documentation.
+func parseDirectives(comments []string) (directives Directives, rest []string) {
+	for _, comment := range comments {
+		if !strings.HasPrefix(comment, directivePrefix) {
+			rest = append(rest, comment)
+			continue
+		}
+		if directives == nil {
+			directives = Directives{}
+		}
+		key, value, _ := strings.Cut(strings.TrimPrefix(comment, directivePrefix), "=")
+		directives[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	// Drop the empty lines separating documentation from directives.
+	for len(rest) > 0 && strings.TrimSpace(rest[len(rest)-1]) == "//" {
+		rest = rest[:len(rest)-1]
+	}
+	return directives, rest
+}
+
+// leadingComments returns the comment lines immediately preceding node,
+// ignoring trailing comments of the previous sibling.
+func leadingComments(src []byte, node *sitter.Node) (comments []string) {
+	for _, comment := range leadingCommentNodes(node) {
+		comments = append(comments, comment.Content(src))
+	}
+	return comments
+}
+
+// leadingCommentNodes returns the comment nodes immediately preceding node,
+// ignoring trailing comments of the previous sibling.
+func leadingCommentNodes(node *sitter.Node) (comments []*sitter.Node) {
+	row := node.StartPoint().Row
+	for prev := node.PrevNamedSibling(); prev != nil && prev.Type() == "comment"; prev = prev.PrevNamedSibling() {
+		// Comments must be contiguous with node.
+		if prev.EndPoint().Row+1 != row {
+			break
+		}
+		// A comment sharing a line with the preceding node belongs to that node.
+		if before := prev.PrevNamedSibling(); before != nil && before.EndPoint().Row == prev.StartPoint().Row {
+			break
+		}
+		comments = append([]*sitter.Node{prev}, comments...)
+		row = prev.StartPoint().Row
+	}
+	return comments
+}
+
+// typeSpecComments returns the comment lines documenting a type_spec node. For
+// ungrouped declarations these precede the enclosing type_declaration.
+func typeSpecComments(src []byte, node *sitter.Node) []string {
+	if comments := leadingComments(src, node); len(comments) > 0 {
+		return comments
+	}
+	if parent := node.Parent(); parent != nil && parent.Type() == "type_declaration" && parent.NamedChildCount() == 1 {
+		return leadingComments(src, parent)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDirectives(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		comments   []string
+		directives Directives
+		rest       []string
+	}{
+		{
+			"no comments",
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"comments without directives are returned",
+			[]string{"// Foo does things.", "//", "// Really."},
+			nil,
+			[]string{"// Foo does things.", "//", "// Really."},
+		},
+		{
+			"directives with and without values",
+			[]string{"// Foo does things.", "//", "//implgen:skip", "//implgen:file=foo_pg.go"},
+			Directives{"skip": "", "file": "foo_pg.go"},
+			[]string{"// Foo does things."},
+		},
+		{
+			"directives must not be spaced",
+			[]string{"// implgen:skip"},
+			nil,
+			[]string{"// implgen:skip"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			directives, rest := parseDirectives(test.comments)
+			require.Equal(test.directives, directives)
+			require.Equal(test.rest, rest)
+		})
+	}
+}
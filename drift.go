@@ -0,0 +1,250 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"log/slog"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// driftRewrite rewrites the signature of drifted methods to match the API.
+	driftRewrite = "rewrite"
+	// driftReport only reports drifted methods.
+	driftReport = "report"
+)
+
+// MethodDrift is an implemented method whose signature differs from the API.
+type MethodDrift struct {
+	// Method is the method declared by the API.
+	Method *Method
+	// Impl is the method declared on the implementation.
+	Impl *ImplMethod
+}
+
+// driftedMethods returns the implemented methods whose parameter or result
+// types differ from those declared by the API. Parameter names are ignored.
+func (r RepositoryImpl) driftedMethods() (drifted []*MethodDrift) {
+	apiQualifiers := aliasedQualifiers(r.Imports)
+	for _, method := range r.Methods {
+		for _, impl := range r.ImplSignatures {
+			if impl.Ident != method.Ident {
+				continue
+			}
+			implQualifiers := aliasedQualifiers(impl.Imports)
+			expected := r.qualifyMethod(method)
+			if !sameTypes(expected.Params, impl.Params, apiQualifiers, implQualifiers) ||
+				!sameTypes(expected.Returns, impl.Returns, apiQualifiers, implQualifiers) {
+				drifted = append(drifted, &MethodDrift{Method: method, Impl: impl})
+			}
+			break
+		}
+	}
+	return drifted
+}
+
+func sameTypes(a, b Params, aQualifiers, bQualifiers map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if canonicalType(a[i].Type, aQualifiers) != canonicalType(b[i].Type, bQualifiers) {
+			return false
+		}
+	}
+	return true
+}
+
+// canonicalType formats typ such that identical types are equal: equivalent
+// types referred to through different import aliases, parameters of function
+// types named or not and fields declared together or apart all format the
+// same. If typ can't be parsed it is returned as is.
+func canonicalType(typ string, qualifiers map[string]string) string {
+	variadic := strings.HasPrefix(typ, "...")
+	expr, err := parser.ParseExpr(strings.TrimPrefix(typ, "..."))
+	if err != nil {
+		return typ
+	}
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				if name, ok := qualifiers[x.Name]; ok {
+					x.Name = name
+				}
+			}
+		}
+		return true
+	})
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncType:
+			n.Params = splitFields(n.Params, false)
+			n.Results = splitFields(n.Results, false)
+		case *ast.StructType:
+			n.Fields = splitFields(n.Fields, true)
+		}
+		return true
+	})
+	if variadic {
+		return "..." + types.ExprString(expr)
+	}
+	return types.ExprString(expr)
+}
+
+// splitFields declares every field of list on its own, dropping the names of
+// the fields unless keepNames is set.
+func splitFields(list *ast.FieldList, keepNames bool) *ast.FieldList {
+	if list == nil {
+		return nil
+	}
+	var fields []*ast.Field
+	for _, field := range list.List {
+		switch {
+		case len(field.Names) == 0:
+			fields = append(fields, field)
+		case keepNames:
+			for _, name := range field.Names {
+				fields = append(fields, &ast.Field{Names: []*ast.Ident{name}, Type: field.Type, Tag: field.Tag})
+			}
+		default:
+			for range field.Names {
+				fields = append(fields, &ast.Field{Type: field.Type})
+			}
+		}
+	}
+	return &ast.FieldList{List: fields}
+}
+
+// aliasedQualifiers maps the aliases of aliased imports to the name the package
+// would be referred to by without the alias.
+func aliasedQualifiers(imports []Import) map[string]string {
+	qualifiers := make(map[string]string)
+	for _, imp := range imports {
+		if imp.Name != "" && imp.Name != "_" && imp.Name != "." {
+			qualifiers[imp.Name] = importName(imp.Path)
+		}
+	}
+	return qualifiers
+}
+
+var majorVersionSuffix = regexp.MustCompile(`[./]v[0-9]+$`)
+
+// importName guesses the name of the package imported by path from its last
+// element, ignoring major version suffixes.
+func importName(importPath string) string {
+	return path.Base(majorVersionSuffix.ReplaceAllString(importPath, ""))
+}
+
+// SignatureSrc returns the parameters and results of the method as declared
+// in the source.
+func (m Method) SignatureSrc() string {
+	src := "(" + m.Params.ParamsSrc() + ")"
+	if returns := m.Returns.ReturnsSrc(); returns != "" {
+		src += " " + returns
+	}
+	return src
+}
+
+// driftEdits returns the edits replacing the signature of every drifted method
+// declared in filename with the signature declared by the API, keeping the
+// names of the parameters and results of the implementation.
+func driftEdits(filename string, repositories []*RepositoryImpl) (edits []srcEdit) {
+	for _, repository := range repositories {
+		apiQualifiers := aliasedQualifiers(repository.Imports)
+		for _, drift := range repository.Drifted {
+			if drift.Impl.Filename != filename {
+				continue
+			}
+			implQualifiers := aliasedQualifiers(drift.Impl.Imports)
+			method := repository.qualifyMethod(drift.Method)
+			method.Params.Qualify()
+			method.Returns.Qualify()
+			keepImplNames(method.Params, drift.Impl.Params, apiQualifiers, implQualifiers)
+			keepImplNames(method.Returns, drift.Impl.Returns, apiQualifiers, implQualifiers)
+			src := "(" + method.Params.src() + ")"
+			if returns := method.Returns.src(); returns != "" {
+				if method.Returns.Named() || len(method.Returns) > 1 {
+					returns = "(" + returns + ")"
+				}
+				src += " " + returns
+			}
+			edits = append(edits, srcEdit{
+				start: drift.Impl.SignatureStart,
+				end:   drift.Impl.SignatureEnd,
+				text:  src,
+			})
+		}
+	}
+	return edits
+}
+
+// keepImplNames renames params after the parameters of the implementation at
+// the same position, so that the body of the method still refers to them. When
+// parameters were added or removed, only those whose type is unchanged are
+// renamed, as the positions may no longer correspond.
+func keepImplNames(params, impl Params, apiQualifiers, implQualifiers map[string]string) {
+	names := make(map[string]bool, len(params))
+	for i, param := range params {
+		if i >= len(impl) || impl[i].Ident == "" {
+			continue
+		}
+		if len(params) != len(impl) &&
+			canonicalType(param.Type, apiQualifiers) != canonicalType(impl[i].Type, implQualifiers) {
+			continue
+		}
+		param.Ident = impl[i].Ident
+		names[param.Ident] = true
+	}
+	named := params.Named()
+	for i, param := range params {
+		kept := i < len(impl) && param.Ident == impl[i].Ident
+		switch {
+		case param.Ident == "" && named:
+			param.Ident = "_"
+		case !kept && param.Ident != "_" && names[param.Ident]:
+			// The name of the API is taken by a parameter of the implementation.
+			param.Ident = "_"
+		}
+	}
+}
+
+// reportDrift logs every drifted method of the implementations in implPackagePath.
+func reportDrift(implPackagePath string, repositories []*RepositoryImpl) {
+	for _, repository := range repositories {
+		for _, drift := range repository.Drifted {
+			attrs := []any{
+				slog.String("method", repository.QualifiedName()+"."+drift.Method.Ident),
+				slog.String("location", path.Join(implPackagePath, drift.Impl.Filename)+":"+strconv.Itoa(drift.Impl.Line)),
+				slog.String("api", repository.qualifyMethod(drift.Method).SignatureSrc()),
+				slog.String("impl", implSignatureSrc(drift.Impl)),
+			}
+			if cli.Drift == driftRewrite {
+				slog.Info("Rewriting method signature that drifted from the API", attrs...)
+			} else {
+				slog.Warn("Method signature drifted from the API", attrs...)
+			}
+		}
+	}
+}
+
+func implSignatureSrc(m *ImplMethod) string {
+	types := func(params Params) string {
+		s := make([]string, len(params))
+		for i, param := range params {
+			s[i] = param.Type
+		}
+		return strings.Join(s, ", ")
+	}
+	src := "(" + types(m.Params) + ")"
+	switch {
+	case len(m.Returns) == 1:
+		src += " " + types(m.Returns)
+	case len(m.Returns) > 1:
+		src += " (" + types(m.Returns) + ")"
+	}
+	return src
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriftedMethods(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		have   RepositoryImpl
+		expect []string
+	}{
+		{
+			"matching signatures do not drift",
+			RepositoryImpl{
+				Repository: Repository{
+					Package: "api",
+					Methods: []*Method{
+						{
+							Ident:   "Get",
+							Params:  Params{{Ident: "ctx", Type: "context.Context"}, {Ident: "id", Type: "ID"}},
+							Returns: Params{{Type: "User"}, {Type: "error"}},
+						},
+					},
+				},
+				ImplSignatures: []*ImplMethod{
+					{
+						Ident:   "Get",
+						Params:  Params{{Ident: "c", Type: "context.Context"}, {Ident: "userID", Type: "api.ID"}},
+						Returns: Params{{Ident: "_", Type: "api.User"}, {Ident: "err", Type: "error"}},
+					},
+				},
+			},
+			nil,
+		},
+		{
+			"grouped parameters and fields are not drift",
+			RepositoryImpl{
+				Repository: Repository{
+					Package: "api",
+					Methods: []*Method{
+						{
+							Ident:   "Each",
+							Params:  Params{{Ident: "fn", Type: "func(a, b int) (ok bool)"}},
+							Returns: Params{{Type: "map[string]struct{ A, B int }"}},
+						},
+					},
+				},
+				ImplSignatures: []*ImplMethod{
+					{
+						Ident:   "Each",
+						Params:  Params{{Ident: "fn", Type: "func(a int, b int) bool"}},
+						Returns: Params{{Type: "map[string]struct {\n\tA int\n\tB int\n}"}},
+					},
+				},
+			},
+			nil,
+		},
+		{
+			"renamed fields drift",
+			RepositoryImpl{
+				Repository: Repository{
+					Package: "api",
+					Methods: []*Method{
+						{Ident: "Pair", Returns: Params{{Type: "struct{ A, B int }"}}},
+					},
+				},
+				ImplSignatures: []*ImplMethod{
+					{Ident: "Pair", Returns: Params{{Type: "struct{ A, C int }"}}},
+				},
+			},
+			[]string{"Pair"},
+		},
+		{
+			"aliased imports are resolved",
+			RepositoryImpl{
+				Repository: Repository{
+					Package: "api",
+					Imports: []Import{{Name: "stdctx", Path: "context"}},
+					Methods: []*Method{
+						{Ident: "Get", Params: Params{{Type: "stdctx.Context"}, {Type: "User"}}},
+					},
+				},
+				ImplSignatures: []*ImplMethod{
+					{
+						Ident:   "Get",
+						Params:  Params{{Type: "context.Context"}, {Type: "apiv1.User"}},
+						Imports: []Import{{Name: "apiv1", Path: "example/api"}},
+					},
+				},
+			},
+			nil,
+		},
+		{
+			"composite types are qualified",
+			RepositoryImpl{
+				Repository: Repository{
+					Package: "api",
+					Methods: []*Method{
+						{
+							Ident:   "List",
+							Params:  Params{{Ident: "ids", Type: "[]ID"}},
+							Returns: Params{{Type: "map[ID]*User"}, {Type: "error"}},
+						},
+					},
+				},
+				ImplSignatures: []*ImplMethod{
+					{
+						Ident:   "List",
+						Params:  Params{{Ident: "ids", Type: "[]api.ID"}},
+						Returns: Params{{Type: "map[api.ID]*api.User"}, {Type: "error"}},
+					},
+				},
+			},
+			nil,
+		},
+		{
+			"aliased imports are resolved inside composite types",
+			RepositoryImpl{
+				Repository: Repository{
+					Package: "api",
+					Imports: []Import{{Name: "stdctx", Path: "context"}},
+					Methods: []*Method{
+						{Ident: "Get", Params: Params{{Type: "func(stdctx.Context) error"}, {Type: "[]*User"}}},
+					},
+				},
+				ImplSignatures: []*ImplMethod{
+					{
+						Ident:   "Get",
+						Params:  Params{{Type: "func(context.Context) error"}, {Type: "[]*apiv1.User"}},
+						Imports: []Import{{Name: "apiv1", Path: "example/api"}},
+					},
+				},
+			},
+			nil,
+		},
+		{
+			"changed element type drifts",
+			RepositoryImpl{
+				Repository: Repository{
+					Package: "api",
+					Methods: []*Method{
+						{Ident: "List", Returns: Params{{Type: "[]*User"}}},
+					},
+				},
+				ImplSignatures: []*ImplMethod{
+					{Ident: "List", Returns: Params{{Type: "[]api.User"}}},
+				},
+			},
+			[]string{"List"},
+		},
+		{
+			"added parameter drifts",
+			RepositoryImpl{
+				Repository: Repository{
+					Package: "api",
+					Methods: []*Method{
+						{Ident: "A", Params: Params{{Ident: "a", Type: "int"}, {Ident: "b", Type: "int"}}},
+						{Ident: "B"},
+					},
+				},
+				ImplSignatures: []*ImplMethod{
+					{Ident: "A", Params: Params{{Ident: "a", Type: "int"}}},
+					{Ident: "B"},
+				},
+			},
+			[]string{"A"},
+		},
+		{
+			"changed result type drifts",
+			RepositoryImpl{
+				Repository: Repository{
+					Package: "api",
+					Methods: []*Method{
+						{Ident: "A", Returns: Params{{Type: "User"}}},
+					},
+				},
+				ImplSignatures: []*ImplMethod{
+					{Ident: "A", Returns: Params{{Type: "*api.User"}}},
+				},
+			},
+			[]string{"A"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			var got []string
+			for _, drift := range test.have.driftedMethods() {
+				got = append(got, drift.Method.Ident)
+			}
+			require.Equal(test.expect, got)
+		})
+	}
+}
+
+func TestRewriteDriftedMethods(t *testing.T) {
+	implSrc := `package internal
+
+import "context"
+
+type userRepositoryImpl struct{}
+
+func (r *userRepositoryImpl) Get(ctx context.Context) (err error) {
+	return nil
+}
+
+func (r *userRepositoryImpl) Delete(id string) {
+	println(id)
+}
+`
+	for _, test := range []struct {
+		name   string
+		drift  string
+		expect string
+	}{
+		{
+			"rewrite replaces the signature and keeps the body",
+			driftRewrite,
+			`package internal
+
+import (
+	"context"
+	"example/api"
+)
+
+type userRepositoryImpl struct{}
+
+func (r *userRepositoryImpl) Get(ctx context.Context, id api.ID) (_ api.User, err error) {
+	return nil
+}
+
+func (r *userRepositoryImpl) Delete(id string) {
+	println(id)
+}
+`,
+		},
+		{
+			"report leaves the signature untouched",
+			driftReport,
+			implSrc,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			defer func(drift string) { cli.Drift = drift }(cli.Drift)
+			cli.Drift = test.drift
+			fsys := fstest.MapFS{
+				"go.mod":            {Data: []byte("module example\n")},
+				"internal/one.go":   {Data: []byte(implSrc)},
+				"api/repository.go": {Data: []byte("package api\n")},
+			}
+			repos := []*Repository{
+				{
+					Package:     "api",
+					PackagePath: "api",
+					Ident:       "UserRepository",
+					Methods: []*Method{
+						{
+							Ident:   "Get",
+							Params:  Params{{Ident: "ctx", Type: "context.Context"}, {Ident: "id", Type: "ID"}},
+							Returns: Params{{Type: "User"}, {Type: "error"}},
+						},
+						{
+							Ident:  "Delete",
+							Params: Params{{Ident: "id", Type: "string"}},
+						},
+					},
+				},
+			}
+			impls, err := parseRepositoryImpls(context.Background(), fsys, "internal", repos)
+			require.NoError(err)
+			require.Len(impls, 1)
+			require.Len(impls[0].Drifted, 1)
+			require.Equal("one.go", impls[0].Drifted[0].Impl.Filename)
+			require.Equal(7, impls[0].Drifted[0].Impl.Line)
+
+			got, err := generateRepositoryImplsForFile(fsys, "internal/one.go", impls)
+			require.NoError(err)
+			require.Equal(test.expect, got)
+		})
+	}
+}
+
+func TestRewriteDriftedMethodsKeepsNames(t *testing.T) {
+	require := require.New(t)
+	defer func(drift string) { cli.Drift = drift }(cli.Drift)
+	cli.Drift = driftRewrite
+	fsys := fstest.MapFS{
+		"go.mod": {Data: []byte("module example\n")},
+		"internal/one.go": {Data: []byte(`package internal
+
+import (
+	"context"
+	"example/api"
+)
+
+type userRepositoryImpl struct{}
+
+func (r *userRepositoryImpl) Get(c context.Context, userID string) (u api.User, e error) {
+	return
+}
+
+func (r *userRepositoryImpl) Delete(c context.Context, id string) {
+	println(id)
+}
+`)},
+		"api/repository.go": {Data: []byte("package api\n")},
+	}
+	repos := []*Repository{
+		{
+			Package:     "api",
+			PackagePath: "api",
+			Ident:       "UserRepository",
+			Methods: []*Method{
+				{
+					Ident:   "Get",
+					Params:  Params{{Ident: "ctx", Type: "context.Context"}, {Ident: "id", Type: "ID"}},
+					Returns: Params{{Type: "*User"}, {Type: "error"}},
+				},
+				{
+					Ident:   "Delete",
+					Params:  Params{{Ident: "ctx", Type: "context.Context"}, {Ident: "id", Type: "string"}, {Ident: "c", Type: "bool"}},
+					Returns: Params{{Type: "error"}},
+				},
+			},
+		},
+	}
+	impls, err := parseRepositoryImpls(context.Background(), fsys, "internal", repos)
+	require.NoError(err)
+	require.Len(impls, 1)
+	require.Len(impls[0].Drifted, 2)
+
+	got, err := generateRepositoryImplsForFile(fsys, "internal/one.go", impls)
+	require.NoError(err)
+	require.Equal(`package internal
+
+import (
+	"context"
+	"example/api"
+)
+
+type userRepositoryImpl struct{}
+
+func (r *userRepositoryImpl) Get(c context.Context, userID api.ID) (u *api.User, e error) {
+	return
+}
+
+func (r *userRepositoryImpl) Delete(c context.Context, id string, _ bool) (err error) {
+	println(id)
+}
+`, got)
+}
+
+func TestRewriteDriftedMethodsInOtherFiles(t *testing.T) {
+	require := require.New(t)
+	defer func(drift string) { cli.Drift = drift }(cli.Drift)
+	cli.Drift = driftRewrite
+	fsys := fstest.MapFS{
+		"go.mod": {Data: []byte("module example\n")},
+		"internal/one.go": {Data: []byte(`package internal
+
+import "example/api"
+
+type userRepositoryImpl struct{}
+
+func (r *userRepositoryImpl) Get() api.User {
+	return api.User{}
+}
+`)},
+		"internal/find.go": {Data: []byte(`package internal
+
+import apiv1 "example/api"
+
+func (r *userRepositoryImpl) Find(id string) apiv1.User {
+	return apiv1.User{}
+}
+`)},
+		"api/repository.go": {Data: []byte("package api\n")},
+	}
+	repos := []*Repository{
+		{
+			Package:     "api",
+			PackagePath: "api",
+			Ident:       "UserRepository",
+			Methods: []*Method{
+				{Ident: "Get", Returns: Params{{Type: "User"}}},
+				{Ident: "Find", Params: Params{{Ident: "id", Type: "ID"}}, Returns: Params{{Type: "User"}}},
+				{Ident: "List", Returns: Params{{Type: "[]User"}}},
+			},
+		},
+	}
+	impls, err := parseRepositoryImpls(context.Background(), fsys, "internal", repos)
+	require.NoError(err)
+	require.Len(impls, 1)
+	require.Len(impls[0].Drifted, 1)
+	grouped := groupByImplFilename(impls)
+	require.Len(grouped, 2)
+
+	// The other file is generated first, so that an alias resolved for it
+	// would leak into the file implementing the repository.
+	find, err := generateRepositoryImplsForFile(fsys, "internal/find.go", grouped["find.go"])
+	require.NoError(err)
+	require.Equal(`package internal
+
+import apiv1 "example/api"
+
+func (r *userRepositoryImpl) Find(id apiv1.ID) apiv1.User {
+	return apiv1.User{}
+}
+`, find)
+	got, err := generateRepositoryImplsForFile(fsys, "internal/one.go", grouped["one.go"])
+	require.NoError(err)
+	require.Contains(got, "func (r *userRepositoryImpl) List() []api.User {")
+	require.NotContains(got, "apiv1")
+}
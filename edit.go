@@ -0,0 +1,24 @@
+package main
+
+import "sort"
+
+// srcEdit replaces the bytes in [start, end) of a source file with text.
+type srcEdit struct {
+	start, end uint32
+	text       string
+}
+
+// applyEdits applies non-overlapping edits to src.
+func applyEdits(src []byte, edits []srcEdit) []byte {
+	// Apply edits from the end of the file so that offsets remain valid.
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+	for _, e := range edits {
+		if int(e.end) > len(src) || e.start > e.end {
+			continue
+		}
+		src = append(src[:e.start:e.start], append([]byte(e.text), src[e.end:]...)...)
+	}
+	return src
+}
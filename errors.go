@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit codes of implgen.
+const (
+	// exitUnchanged is returned when generation succeeded.
+	exitUnchanged = 0
+	// exitError is returned when generation failed.
+	exitError = 1
+	// exitChanged is returned in dry-run and check mode when files would be
+	// created or changed, or with --exit-code when they were.
+	exitChanged = 2
+)
+
+// exitCode returns the exit code of a run that returned err, reporting whether
+// files were, or would be, created or changed.
+func exitCode(changed bool, err error) int {
+	switch {
+	case errors.Is(err, errStale):
+		return exitChanged
+	case err != nil:
+		return exitError
+	case changed && (cli.DryRun || cli.Check || cli.ExitCode):
+		return exitChanged
+	}
+	return exitUnchanged
+}
+
+// ParseError is returned when the API definitions or implementation files of a
+// package can't be parsed.
+type ParseError struct {
+	Path string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse %s: %v", e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// GenerateError is returned when the source of a file can't be generated.
+type GenerateError struct {
+	Path string
+	Err  error
+}
+
+func (e *GenerateError) Error() string {
+	return fmt.Sprintf("failed to generate %s: %v", e.Path, e.Err)
+}
+
+func (e *GenerateError) Unwrap() error {
+	return e.Err
+}
+
+// WriteError is returned when a generated file can't be written.
+type WriteError struct {
+	Path string
+	Err  error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("failed to write %s: %v", e.Path, e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// unjoinErrors returns the errors joined in err, or err itself.
+func unjoinErrors(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+// ConfigError is returned when the configuration file is invalid.
+type ConfigError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid configuration %s: %v", e.Path, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		err    error
+		expect string
+	}{
+		{
+			"parse",
+			&ParseError{Path: "api/users", Err: errors.New("unexpected token")},
+			"failed to parse api/users: unexpected token",
+		},
+		{
+			"generate",
+			&GenerateError{Path: "internal/users/user_repository_impl.go", Err: errors.New("invalid source")},
+			"failed to generate internal/users/user_repository_impl.go: invalid source",
+		},
+		{
+			"write",
+			&WriteError{Path: "internal/repositories.go", Err: fs.ErrPermission},
+			"failed to write internal/repositories.go: permission denied",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			require.EqualError(test.err, test.expect)
+			require.NotNil(errors.Unwrap(test.err))
+		})
+	}
+}
+
+func TestUnjoinErrors(t *testing.T) {
+	require := require.New(t)
+	parseErr := &ParseError{Path: "api/users", Err: errors.New("unexpected token")}
+	writeErr := &WriteError{Path: "internal/repositories.go", Err: fs.ErrPermission}
+
+	require.Equal([]error{parseErr}, unjoinErrors(parseErr))
+	joined := errors.Join(parseErr, writeErr)
+	require.Equal([]error{parseErr, writeErr}, unjoinErrors(joined))
+	require.ErrorIs(joined, fs.ErrPermission)
+}
+
+func TestExitCode(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		changed bool
+		err     error
+		dryRun  bool
+		check   bool
+		exit    bool
+		expect  int
+	}{
+		{"writing files succeeds", true, nil, false, false, false, exitUnchanged},
+		{"nothing to write succeeds", false, nil, false, false, false, exitUnchanged},
+		{"exit code reports written files", true, nil, false, false, true, exitChanged},
+		{"dry run reports changes", true, nil, true, false, false, exitChanged},
+		{"dry run without changes succeeds", false, nil, true, false, false, exitUnchanged},
+		{"check reports stale code", true, errStale, false, true, false, exitChanged},
+		{"failures are errors", true, &WriteError{Path: "a.go", Err: fs.ErrPermission}, false, false, false, exitError},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(dryRun, check, exit bool) {
+				cli.DryRun, cli.Check, cli.ExitCode = dryRun, check, exit
+			}(cli.DryRun, cli.Check, cli.ExitCode)
+			cli.DryRun, cli.Check, cli.ExitCode = test.dryRun, test.check, test.exit
+			require.Equal(t, test.expect, exitCode(test.changed, test.err))
+		})
+	}
+}
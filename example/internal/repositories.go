@@ -5,9 +5,9 @@ package internal
 //go:generate moq -out=waltuh/mocks.go -pkg=waltuhimpl -rm -skip-ensure ../api/waltuh AnotherRepository BRepository Repository
 
 import (
-	"go.uber.org/fx"
-
 	waltuhimpl "example/internal/waltuh"
+
+	"go.uber.org/fx"
 )
 
 var Repositories = fx.Options(
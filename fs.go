@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"go/ast"
 	"io"
 	"io/fs"
+	"os"
 	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"golang.org/x/mod/modfile"
 )
@@ -62,6 +68,16 @@ func computeImplPackagePath(apiRoot, implRoot, apiPackagePath string) (string, e
 	return implPackagePath, nil
 }
 
+// readFile reads the named file from fsys.
+func readFile(fsys fs.FS, name string) ([]byte, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
 var cachedModule string
 
 // getModule recursively searches (upwards) for a go.mod file and returns the module path.
@@ -131,3 +147,283 @@ func loadLocalPackage(
 	}
 	return
 }
+
+// WriteFS is a file system that can be written to. Paths are slash-separated
+// and relative to the root of the file system, as with fs.FS.
+type WriteFS interface {
+	fs.StatFS
+	MkdirAll(name string, perm fs.FileMode) error
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+var errOutsideRoot = errors.New("path resolves outside of root")
+
+// diskFS is a WriteFS backed by the directory tree at root.
+type diskFS struct {
+	fs.StatFS
+	root string
+}
+
+func newDiskFS(root string) *diskFS {
+	return &diskFS{
+		StatFS: os.DirFS(root).(fs.StatFS),
+		root:   root,
+	}
+}
+
+// resolve returns the OS path of name, refusing paths that resolve outside of
+// the root, including through symbolic links.
+func (d *diskFS) resolve(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	root, err := filepath.EvalSymlinks(d.root)
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	full := filepath.Join(root, filepath.FromSlash(name))
+	// Resolve the deepest existing ancestor, as the rest doesn't exist yet.
+	existing := full
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &fs.PathError{Op: op, Path: name, Err: errOutsideRoot}
+	}
+	return full, nil
+}
+
+func (d *diskFS) MkdirAll(name string, perm fs.FileMode) error {
+	full, err := d.resolve("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(full, perm)
+}
+
+// WriteFile atomically writes data to name by writing to a temporary file in
+// the same directory and renaming it.
+func (d *diskFS) WriteFile(name string, data []byte, perm fs.FileMode) (err error) {
+	full, err := d.resolve("write", name)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(full), "."+filepath.Base(full)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), full)
+}
+
+// memFS is an in-memory WriteFS. Directories are implied by the paths of the
+// files they contain, besides those created by MkdirAll.
+type memFS struct {
+	files map[string]*memFile
+}
+
+type memFile struct {
+	data []byte
+	mode fs.FileMode
+}
+
+func newMemFS() *memFS {
+	return &memFS{files: map[string]*memFile{}}
+}
+
+// readFile returns the contents of the regular file name, reporting whether
+// it exists.
+func (m *memFS) readFile(name string) ([]byte, bool) {
+	file, ok := m.files[name]
+	if !ok || file.mode.IsDir() {
+		return nil, false
+	}
+	return file.data, true
+}
+
+func (m *memFS) Open(name string) (fs.File, error) {
+	info, err := m.Stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if info.IsDir() {
+		entries, err := m.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &memDir{info: info, entries: entries}, nil
+	}
+	return &memOpenFile{info: info, Reader: bytes.NewReader(m.files[name].data)}, nil
+}
+
+func (m *memFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if file, ok := m.files[name]; ok {
+		return memFileInfo{name: path.Base(name), file: file}, nil
+	}
+	prefix := name + "/"
+	for child := range m.files {
+		if name == "." || strings.HasPrefix(child, prefix) {
+			return memFileInfo{name: path.Base(name), file: &memFile{mode: fs.ModeDir | 0755}}, nil
+		}
+	}
+	if name == "." {
+		return memFileInfo{name: ".", file: &memFile{mode: fs.ModeDir | 0755}}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir returns the entries of the directory name, sorted by name.
+func (m *memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	info, err := m.Stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	seen := map[string]bool{}
+	var entries []fs.DirEntry
+	for child := range m.files {
+		if !strings.HasPrefix(child, prefix) {
+			continue
+		}
+		entryName, _, _ := strings.Cut(child[len(prefix):], "/")
+		if seen[entryName] {
+			continue
+		}
+		seen[entryName] = true
+		info, err := m.Stat(prefix + entryName)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *memFS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if file, ok := m.files[dir]; ok {
+			if !file.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+			}
+			continue
+		}
+		m.files[dir] = &memFile{mode: fs.ModeDir | perm}
+	}
+	return nil
+}
+
+func (m *memFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	if file, ok := m.files[name]; ok && file.mode.IsDir() {
+		return &fs.PathError{Op: "write", Path: name, Err: syscall.EISDIR}
+	}
+	m.files[name] = &memFile{data: bytes.Clone(data), mode: perm}
+	return nil
+}
+
+type memFileInfo struct {
+	name string
+	file *memFile
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return int64(len(i.file.data)) }
+func (i memFileInfo) Mode() fs.FileMode  { return i.file.mode }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return i.file.mode.IsDir() }
+func (i memFileInfo) Sys() any           { return nil }
+
+// memOpenFile is a regular file of a memFS opened for reading.
+type memOpenFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *memOpenFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memOpenFile) Close() error               { return nil }
+
+// memDir is a directory of a memFS opened for reading.
+type memDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: syscall.EISDIR}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// writeFile writes data to name, creating its directory if needed. The file is
+// left untouched if it already has the same contents, and changed reports
+// whether it was written.
+func writeFile(fsys WriteFS, name string, data []byte) (changed bool, err error) {
+	existing, err := readFile(fsys, name)
+	if err == nil && bytes.Equal(existing, data) {
+		return false, nil
+	}
+	if err := fsys.MkdirAll(path.Dir(name), 0755); err != nil {
+		return false, &WriteError{Path: name, Err: err}
+	}
+	if err := fsys.WriteFile(name, data, 0644); err != nil {
+		return false, &WriteError{Path: name, Err: err}
+	}
+	return true, nil
+}
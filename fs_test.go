@@ -2,6 +2,9 @@ package main
 
 import (
 	"go/ast"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
 	"testing/fstest"
 
@@ -257,3 +260,98 @@ func TestLoadLocalPackage(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteFS(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		fsys func(t *testing.T) WriteFS
+	}{
+		{
+			"disk",
+			func(t *testing.T) WriteFS {
+				return newDiskFS(t.TempDir())
+			},
+		},
+		{
+			"memory",
+			func(t *testing.T) WriteFS {
+				return newMemFS()
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			fsys := test.fsys(t)
+
+			_, err := fs.Stat(fsys, "internal/users/users.go")
+			require.ErrorIs(err, fs.ErrNotExist)
+
+			require.NoError(fsys.MkdirAll("internal/users", 0755))
+			require.NoError(fsys.WriteFile("internal/users/users.go", []byte("package users\n"), 0644))
+			require.NoError(fsys.WriteFile("internal/users/users.go", []byte("package usersimpl\n"), 0644))
+
+			info, err := fs.Stat(fsys, "internal/users")
+			require.NoError(err)
+			require.True(info.IsDir())
+			data, err := readFile(fsys, "internal/users/users.go")
+			require.NoError(err)
+			require.Equal("package usersimpl\n", string(data))
+
+			entries, err := fs.ReadDir(fsys, "internal/users")
+			require.NoError(err)
+			require.Len(entries, 1, "no temporary files should be left behind")
+
+			require.Error(fsys.WriteFile("../outside.go", nil, 0644))
+			require.Error(fsys.WriteFile("/outside.go", nil, 0644))
+			require.Error(fsys.MkdirAll("internal/../../outside", 0755))
+		})
+	}
+}
+
+func TestDiskFSRefusesSymlinksOutsideRoot(t *testing.T) {
+	require := require.New(t)
+	root := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(os.Symlink(outside, filepath.Join(root, "internal")))
+	fsys := newDiskFS(root)
+
+	err := fsys.WriteFile("internal/users.go", []byte("package internal\n"), 0644)
+	require.ErrorIs(err, errOutsideRoot)
+	err = fsys.MkdirAll("internal/users", 0755)
+	require.ErrorIs(err, errOutsideRoot)
+
+	entries, err := os.ReadDir(outside)
+	require.NoError(err)
+	require.Empty(entries)
+}
+
+func TestWriteFile(t *testing.T) {
+	require := require.New(t)
+	fsys := newMemFS()
+
+	changed, err := writeFile(fsys, "internal/users/users.go", []byte("package users\n"))
+	require.NoError(err)
+	require.True(changed)
+
+	changed, err = writeFile(fsys, "internal/users/users.go", []byte("package users\n"))
+	require.NoError(err)
+	require.False(changed, "identical contents should not be rewritten")
+
+	changed, err = writeFile(fsys, "internal/users/users.go", []byte("package usersimpl\n"))
+	require.NoError(err)
+	require.True(changed)
+
+	_, err = writeFile(fsys, "internal/users/users.go/nested.go", nil)
+	var writeErr *WriteError
+	require.ErrorAs(err, &writeErr)
+	require.Equal("internal/users/users.go/nested.go", writeErr.Path)
+}
+
+func TestMemFS(t *testing.T) {
+	require := require.New(t)
+	fsys := newMemFS()
+	require.NoError(fsys.MkdirAll("internal/empty", 0755))
+	require.NoError(fsys.WriteFile("internal/users/users.go", []byte("package users\n"), 0644))
+	require.NoError(fsys.WriteFile("go.mod", []byte("module example\n"), 0644))
+	require.NoError(fstest.TestFS(fsys, "go.mod", "internal/empty", "internal/users/users.go"))
+}
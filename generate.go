@@ -7,14 +7,16 @@ import (
 	"fmt"
 	"go/ast"
 	"go/parser"
+	"go/types"
 	"io"
 	"io/fs"
 	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
-	"text/template"
+	"unicode"
 
 	"golang.org/x/tools/imports"
 )
@@ -26,6 +28,9 @@ func (r RepositoryImpl) ImplTestPackage() string {
 func (r RepositoryImpl) NewMethods() []*Method {
 	methods := []*Method{}
 	for _, method := range r.Methods {
+		if method.Directives.Has("skip") {
+			continue
+		}
 		existing := false
 		for _, existingMethod := range r.ImplMethods {
 			if method.Ident == existingMethod {
@@ -36,57 +41,55 @@ func (r RepositoryImpl) NewMethods() []*Method {
 		if existing {
 			continue
 		}
-		args := make(Params, len(method.Params))
-		returns := make(Params, len(method.Returns))
-		qualify := func(arg *Param) *Param {
-			isLower := 'a' <= arg.Type[0] && arg.Type[0] <= 'z'
-			if strings.Contains(arg.Type, ".") || isLower {
-				return arg
-			}
-			return &Param{
-				Ident: arg.Ident,
-				Type:  r.Package + "." + arg.Type,
-			}
-		}
-		for i, arg := range method.Params {
-			arg := arg
-			args[i] = qualify(arg)
-		}
-		for i, arg := range method.Returns {
-			arg := arg
-			returns[i] = qualify(arg)
-		}
-		if len(args) == 0 {
-			args = nil
-		}
-		if len(returns) == 0 {
-			returns = nil
-		}
-		methods = append(methods, &Method{
-			Ident:   method.Ident,
-			Params:  args,
-			Returns: returns,
-		})
+		methods = append(methods, r.qualifyMethod(method))
 	}
 	return methods
 }
 
-func (p Params) HasCtx() bool {
-	for _, param := range p {
-		if param.Type == "context.Context" {
-			return true
+// qualifyMethod returns a copy of method with the types declared in the API
+// package qualified with the package name.
+func (r Repository) qualifyMethod(method *Method) *Method {
+	args := make(Params, len(method.Params))
+	returns := make(Params, len(method.Returns))
+	qualify := func(arg *Param) *Param {
+		return &Param{
+			Ident:      arg.Ident,
+			Type:       r.qualifyType(arg.Type),
+			Directives: arg.Directives,
+			Role:       arg.Role,
 		}
 	}
-	return false
+	for i, arg := range method.Params {
+		arg := arg
+		args[i] = qualify(arg)
+	}
+	for i, arg := range method.Returns {
+		arg := arg
+		returns[i] = qualify(arg)
+	}
+	if len(args) == 0 {
+		args = nil
+	}
+	if len(returns) == 0 {
+		returns = nil
+	}
+	return &Method{
+		Ident:      method.Ident,
+		Params:     args,
+		Returns:    returns,
+		Directives: method.Directives,
+		Doc:        method.Doc,
+	}
+}
+
+// HasCtx reports whether a parameter is a context.Context or context-like.
+func (p Params) HasCtx() bool {
+	return p.has(roleContext, roleContextLike)
 }
 
+// HasError reports whether a parameter is an error or error-like.
 func (p Params) HasError() bool {
-	for _, param := range p {
-		if param.Type == "error" {
-			return true
-		}
-	}
-	return false
+	return p.has(roleError, roleErrorLike)
 }
 
 func (p Params) Named() bool {
@@ -106,10 +109,10 @@ func (p Params) Qualify() {
 		return
 	}
 	for _, param := range p {
-		switch param.Type {
-		case "context.Context":
+		switch param.role() {
+		case roleContext, roleContextLike:
 			param.Ident = "ctx"
-		case "error":
+		case roleError, roleErrorLike:
 			param.Ident = "err"
 		default:
 			if param.Ident == "" {
@@ -119,14 +122,20 @@ func (p Params) Qualify() {
 	}
 }
 
-func (p Params) ParamsSrc() (s string) {
+func (p Params) ParamsSrc() string {
 	p.Qualify()
+	return p.src()
+}
+
+// src returns the parameters as declared in the source, without naming them.
+func (p Params) src() (s string) {
 	n := len(p)
 	for i, param := range p {
 		if i > 0 {
 			s += ", "
 		}
-		if i < n-1 && param.Type == p[i+1].Type {
+		// Only named parameters of the same type can be grouped.
+		if i < n-1 && param.Ident != "" && p[i+1].Ident != "" && param.Type == p[i+1].Type {
 			s += param.Ident
 		} else if param.Ident != "" {
 			s += param.Ident + " " + param.Type
@@ -140,23 +149,37 @@ func (p Params) ParamsSrc() (s string) {
 func (p Params) ReturnsSrc() string {
 	p.Qualify()
 	src := p.ParamsSrc()
-	if p.Named() {
+	if p.Named() || len(p) > 1 {
 		return "(" + src + ")"
 	}
 	return src
 }
 
+func (r Repository) profile() *Profile {
+	if r.Profile == nil {
+		return defaultProfile
+	}
+	return r.Profile
+}
+
 func (r Repository) QualifyString(s string) string {
 	name := r.Name()
-	if name == "Repository" {
-		return s
+	if name == r.profile().Suffix {
+		name = ""
 	}
-	return name + s
+	return upperFirst(r.Variant()) + name + s
+}
+
+// Variant returns the name given to the implementation by an impl directive,
+// used to prefix its declarations, e.g. postgres -> postgresUserRepositoryImpl.
+func (r Repository) Variant() string {
+	return r.Directives.Get("impl")
 }
 
 func (r Repository) Name() string {
-	if len(r.Ident) > 10 && strings.HasSuffix(r.Ident, "Repository") {
-		return r.Ident[:len(r.Ident)-10]
+	suffix := r.profile().Suffix
+	if len(r.Ident) > len(suffix) && strings.HasSuffix(r.Ident, suffix) {
+		return r.Ident[:len(r.Ident)-len(suffix)]
 	}
 	return r.Ident
 }
@@ -165,8 +188,97 @@ func (r Repository) ImplName() string {
 	if r.Ident == "" {
 		return ""
 	}
-	name := r.Ident
-	return strings.ToLower(string(name[0])) + name[1:] + "Impl"
+	if variant := r.Variant(); variant != "" {
+		return lowerFirst(variant) + r.Ident + r.profile().ImplSuffix
+	}
+	return lowerFirst(r.Ident) + r.profile().ImplSuffix
+}
+
+// ConstructorName returns the name of the function constructing the implementation.
+func (r Repository) ConstructorName() string {
+	return "New" + upperFirst(r.Variant()) + r.Ident
+}
+
+// Traces reports whether a span should be started in the implementation of method.
+func (r Repository) Traces(method Method) bool {
+	return method.Params.HasCtx() &&
+		config.forPackage(r.PackagePath).Tracing != tracingNone &&
+		!r.Directives.Has("notrace") &&
+		!method.Directives.Has("notrace")
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// DependenciesName returns the name of the dependencies struct of the implementation.
+func (r Repository) DependenciesName() string {
+	return r.QualifyString(r.profile().Dependencies)
+}
+
+// OptionsName returns the name of the options variable of the implementation.
+func (r Repository) OptionsName() string {
+	return r.QualifyString(r.profile().Options)
+}
+
+// qualifyType qualifies every type declared in the API package referred to by
+// the type expression typ with the package name, e.g. []*User -> []*api.User.
+// Predeclared types and type parameters are left as is.
+func (r Repository) qualifyType(typ string) string {
+	if typ == "" {
+		return typ
+	}
+	return rewriteTypeExpr(typ, func(ident string) string {
+		if types.Universe.Lookup(ident) != nil || r.isTypeParam(ident) {
+			return ident
+		}
+		return r.Package + "." + ident
+	})
+}
+
+func (r Repository) isTypeParam(ident string) bool {
+	for _, param := range r.TypeParams {
+		if param.Ident == ident {
+			return true
+		}
+	}
+	return false
+}
+
+// TypeParamsDecl returns the type parameter list used to declare generic
+// implementations, e.g. [T any, ID comparable]. It is empty for non-generic repositories.
+func (r Repository) TypeParamsDecl() string {
+	if len(r.TypeParams) == 0 {
+		return ""
+	}
+	params := make([]string, len(r.TypeParams))
+	for i, param := range r.TypeParams {
+		params[i] = param.Ident + " " + r.qualifyType(param.Type)
+	}
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+// TypeArgs returns the type parameter names used to instantiate generic
+// implementations, e.g. [T, ID]. It is empty for non-generic repositories.
+func (r Repository) TypeArgs() string {
+	if len(r.TypeParams) == 0 {
+		return ""
+	}
+	args := make([]string, len(r.TypeParams))
+	for i, param := range r.TypeParams {
+		args[i] = param.Ident
+	}
+	return "[" + strings.Join(args, ", ") + "]"
 }
 
 func (r Repository) QualifiedName() string {
@@ -177,27 +289,45 @@ func (r Repository) QualifiedName() string {
 }
 
 const generateMethodTemplate = `
-  func (r *{{ .Repository.ImplName }}) {{ .Method.Ident }}({{ .Method.Params.ParamsSrc }}){{ pad .Method.Returns.ReturnsSrc }}{
-  {{- if .Method.Params.HasCtx }}
-    ctx, span := otel.GetTracerProvider().Tracer("{{ .Repository.Package }}").Start(ctx, "{{ .Repository.Name }}.{{ .Method.Ident }}")
-    {{- if .Method.Returns.HasError }}
+{{- with .Method.Doc }}
+  // {{ $.Method.Ident }} implements {{ $.Repository.QualifiedName }}.{{ $.Method.Ident }}.
+  //
+  {{- range . }}
+  {{ . }}
+  {{- end }}
+{{- end }}
+  func (r *{{ .Repository.ImplName }}{{ .Repository.TypeArgs }}) {{ .Method.Ident }}({{ .Method.Params.ParamsSrc }}){{ pad .Method.Returns.ReturnsSrc }}{
+  {{- if .Repository.Traces .Method }}
+    {{ .Repository.StartSpan .Method }}
+    {{- range .Repository.SpanParamAttributes .Method }}
+    {{ . }}
+    {{- end }}
+    {{- if or .Method.Returns.HasError (.Repository.SpanResultAttributes .Method) }}
     defer func() {
+      {{- range $.Repository.SpanResultAttributes $.Method }}
+      {{ . }}
+      {{- end }}
+      {{- if and .Method.Returns.HasError (or (.Repository.WrapError .Method) .Repository.RecordSpanError) }}
       if err != nil {
-        err = eris.Wrap(err, "{{ .Repository.QualifiedName }}.{{ .Method.Ident }}")
-        span.SetStatus(codes.Error, "")
-        span.RecordError(err)
+        {{- with $.Repository.WrapError $.Method }}
+        {{ . }}
+        {{- end }}
+        {{- range $.Repository.RecordSpanError }}
+        {{ . }}
+        {{- end }}
       }
-      span.End()
+      {{- end }}
+      {{ .Repository.EndSpan .Method }}
     }()
     {{- else }}
-    defer span.End()
+    defer {{ .Repository.EndSpan .Method }}
     {{- end }}
-    _ = ctx
+    _ = {{ .Repository.SpanContext .Method }}
   {{- else }}
-    {{- if .Method.Returns.HasError }}
+    {{- with .Repository.WrapError .Method }}
     defer func() {
       if err != nil {
-        err = eris.Wrap(err, "{{ .Repository.QualifiedName }}.{{ .Method.Ident }}")
+        {{ . }}
       }
     }()
     {{- end }}
@@ -207,68 +337,75 @@ const generateMethodTemplate = `
 `
 
 func generateMethodImpl(repository Repository, method Method) (string, error) {
-	tmpl, err := template.
-		New("generateMethodTemplate").
-		Funcs(template.FuncMap{
-			"pad": func(s string) string {
-				if s == "" {
-					return " "
-				}
-				return " " + s + " "
-			},
-		}).
-		Parse(generateMethodTemplate)
+	templates, err := repository.templates()
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
-	}
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, struct {
-		Repository
-		Method
-	}{repository, method}); err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
+		return "", err
 	}
-	return buf.String(), nil
+	return executeTemplate(templateMethod, templates.Method, MethodTemplateData{repository, method})
 }
 
 const generateRepositoryImplTemplate = `
-type {{ .Repository.QualifyString "Dependencies" }} struct {
+type {{ .Repository.DependenciesName }} struct {
   fx.In
 	// Add dependencies here
 }
 
-var {{ .Repository.QualifyString "Options" }} = fx.Options(
+{{ if .Repository.TypeParams -}}
+func {{ .Repository.OptionsName }}{{ .Repository.TypeParamsDecl }}() fx.Option {
+	return fx.Options(
+		fx.Provide(
+			{{ .Repository.ConstructorName }}{{ .Repository.TypeArgs }},
+		),
+	)
+}
+{{- else -}}
+var {{ .Repository.OptionsName }} = fx.Options(
 	fx.Provide(
-		New{{ .Repository.Ident }},
+		{{ .Repository.ConstructorName }},
 	),
 )
+{{- end }}
 
-func New{{ .Repository.Ident }}(deps {{ .Repository.QualifyString "Dependencies" }}) {{ .Repository.Package }}.{{ .Repository.Ident }} {
-	return &{{ .Repository.ImplName }}{
-    {{ .Repository.QualifyString "Dependencies" }}: deps,
+func {{ .Repository.ConstructorName }}{{ .Repository.TypeParamsDecl }}({{ if .Repository.HasLifecycle }}lc fx.Lifecycle, {{ end }}deps {{ .Repository.DependenciesName }}) {{ .Repository.Package }}.{{ .Repository.Ident }}{{ .Repository.TypeArgs }} {
+{{- if .Repository.HasLifecycle }}
+	impl := &{{ .Repository.ImplName }}{{ .Repository.TypeArgs }}{
+    {{ .Repository.DependenciesName }}: deps,
+	}
+	lc.Append(fx.Hook{
+		{{- with .Repository.OnStartHook }}
+		OnStart: {{ . }},
+		{{- end }}
+		{{- with .Repository.OnStopHook }}
+		OnStop: {{ . }},
+		{{- end }}
+	})
+	return impl
+{{- else }}
+	return &{{ .Repository.ImplName }}{{ .Repository.TypeArgs }}{
+    {{ .Repository.DependenciesName }}: deps,
 	}
+{{- end }}
 }
 
-type {{ .Repository.ImplName }} struct {
-  {{ .Repository.QualifyString "Dependencies" }}
+{{ with .Repository.Doc -}}
+// {{ $.Repository.ImplName }} implements {{ $.Repository.QualifiedName }}.
+//
+{{ range . -}}
+{{ . }}
+{{ end -}}
+{{ end -}}
+type {{ .Repository.ImplName }}{{ .Repository.TypeParamsDecl }} struct {
+  {{ .Repository.DependenciesName }}
 }
 `
 
 // generateRepositoryImpl generates the method and struct declarations for a single repository.
 func generateRepositoryImpl(repository Repository) (string, error) {
-	tmpl, err := template.
-		New("generateRepositoryImplTemplate").
-		Parse(generateRepositoryImplTemplate)
+	templates, err := repository.templates()
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
-	}
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, struct {
-		Repository
-	}{repository}); err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
+		return "", err
 	}
-	return buf.String(), nil
+	return executeTemplate(templateImpl, templates.Impl, ImplTemplateData{repository})
 }
 
 // generateRepositoryImplsForFile generates the repository implementations for a single file.
@@ -282,6 +419,7 @@ func generateRepositoryImplsForFile(
 	if len(repositories) == 0 {
 		return "", nil
 	}
+	filename := path.Base(filepath)
 	var (
 		originalSrc        []byte
 		originalSrcScanner *bufio.Scanner
@@ -292,7 +430,6 @@ func generateRepositoryImplsForFile(
 	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return "", err
 	}
-	// Write package declaration to src. If the file does not exist, write a new package declaration.
 	if file != nil {
 		defer file.Close()
 		originalSrc, err = io.ReadAll(file)
@@ -303,35 +440,71 @@ func generateRepositoryImplsForFile(
 		if err != nil {
 			return "", err
 		}
-		originalSrcScanner = bufio.NewScanner(bytes.NewReader(originalSrc))
-		for originalSrcScanner.Scan() {
-			line := originalSrcScanner.Text()
-			src.WriteString(line + "\n")
-			if strings.HasPrefix(line, "package") {
-				break
-			}
-		}
-	} else {
-		packageDecl := fmt.Sprintf(`
-// This file will be automatically regenerated based on the API. Any repository implementations
-// will be copied through when generating and new methods will be added to the end.
-package %s
-`, repositories[0].ImplPackage)
-		src.WriteString(strings.TrimPrefix(packageDecl, "\n"))
 	}
 
-	// Add imports to src
+	// Packages are referred to by the names they are imported under in this
+	// file, so the names are resolved on copies of the repositories. Only the
+	// repositories implemented in this file are declared or get new methods,
+	// the others only have their methods edited.
+	local := make([]*RepositoryImpl, len(repositories))
+	var implemented []*RepositoryImpl
+	for i, repository := range repositories {
+		copied := *repository
+		local[i] = &copied
+		if copied.ImplFilename == filename {
+			implemented = append(implemented, &copied)
+		}
+	}
+	repositories = local
 	requiredImports, err := collectImports(
 		fsys,
 		astFile,
 		true,
 		false,
 		nil,
+		implemented,
 		repositories...,
 	)
 	if err != nil {
 		return "", err
 	}
+
+	// Write package declaration to src. If the file does not exist, write a new package declaration.
+	if file != nil {
+		// Package aliases are resolved by collectImports, so methods can only
+		// be edited afterwards.
+		var edits []srcEdit
+		if cli.Drift == driftRewrite {
+			edits = append(edits, driftEdits(filename, repositories)...)
+		}
+		edits = append(edits, orphanEdits(filename, repositories)...)
+		originalSrc = applyEdits(originalSrc, edits)
+		originalSrcScanner = bufio.NewScanner(bytes.NewReader(originalSrc))
+		for originalSrcScanner.Scan() {
+			line := originalSrcScanner.Text()
+			src.WriteString(line + "\n")
+			if strings.HasPrefix(line, "package") {
+				break
+			}
+		}
+	} else {
+		templates, err := repositories[0].templates()
+		if err != nil {
+			return "", err
+		}
+		header, err := executeTemplate(templateHeader, templates.Header, HeaderTemplateData{
+			Package: repositories[0].ImplPackage,
+			Imports: requiredImports,
+		})
+		if err != nil {
+			return "", err
+		}
+		src.WriteString(header)
+		// The header declares the imports of new files.
+		requiredImports = nil
+	}
+
+	// Add imports to src
 	for _, imp := range requiredImports {
 		src.WriteString("import ")
 		if imp.Name != "" {
@@ -348,7 +521,7 @@ package %s
 	}
 
 	// Append new repository declarations
-	for _, repository := range repositories {
+	for _, repository := range implemented {
 		if !repository.IsNew {
 			continue
 		}
@@ -360,7 +533,7 @@ package %s
 	}
 
 	// Append new methods
-	for _, repository := range repositories {
+	for _, repository := range implemented {
 		for _, newMethod := range repository.NewMethods() {
 			methodImpl, err := generateMethodImpl(repository.Repository, *newMethod)
 			if err != nil {
@@ -372,7 +545,7 @@ package %s
 	return formatImports(filepath, src.Bytes())
 }
 
-var repositoryStubFileTemplate = `
+const repositoryStubFileTemplate = `
 // DO NOT MODIFY
 // This file will be automatically regenerated based on the API.
 package {{ .Package }}
@@ -383,12 +556,13 @@ package {{ .Package }}
 {{ range .Imports }}
 import {{ .Name }} "{{ .Path }}"
 {{- end }}
-
-var Repositories = fx.Options(
+{{ range .Registries }}
+var {{ .Name }} = fx.Options(
 {{ range .Repositories -}}
-  {{ .ImplPackage }}.{{ .QualifyString "Options" }},
+  {{ .ImplPackage }}.{{ .OptionsName }},
 {{ end -}}
 )
+{{ end -}}
 `
 
 func generateRepositoryStubFile(
@@ -396,46 +570,35 @@ func generateRepositoryStubFile(
 	packagePath string,
 	repositories ...*RepositoryImpl,
 ) (string, error) {
-	type MockDirective struct {
-		Src          string
-		Dst          string
-		ImplPackage  string
-		Repositories []string
-	}
-	var templateData struct {
-		Package        string
-		Imports        []Import
-		Repositories   []*RepositoryImpl
-		MockDirectives []MockDirective
-	}
-	sort.Slice(repositories, func(i, j int) bool {
+	var templateData StubTemplateData
+	sort.SliceStable(repositories, func(i, j int) bool {
 		a := repositories[i]
 		b := repositories[j]
-		if a.ImplPackage == b.ImplPackage {
-			if a.Ident == "Repository" {
-				return true
-			}
-			if b.Ident == "Repository" {
-				return false
-			}
+		if a.ImplPackage != b.ImplPackage {
+			return a.ImplPackage < b.ImplPackage
+		}
+		if a.ImplPackagePath != b.ImplPackagePath {
+			return a.ImplPackagePath < b.ImplPackagePath
+		}
+		aBare, bBare := a.Ident == a.profile().Suffix, b.Ident == b.profile().Suffix
+		if aBare != bBare {
+			return aBare
+		}
+		if a.Ident != b.Ident {
 			return a.Ident < b.Ident
 		}
-		return a.ImplPackage < b.ImplPackage
+		return a.ImplName() < b.ImplName()
 	})
 
-	mocked := map[string]bool{}
 	for _, repositories := range groupByPackage(repositories) {
 		repository := repositories[0]
-		src := repository.PackagePath
-		if _, done := mocked[src]; done {
+		if config.forPackage(repository.PackagePath).Mocks == mocksNone {
 			continue
 		}
-		var err error
-		src, err = filepath.Rel(cli.Impl, src)
+		src, err := filepath.Rel(cli.Impl, repository.PackagePath)
 		if err != nil {
 			return "", fmt.Errorf("failed to get relative path: %w", err)
 		}
-		mocked[src] = true
 		dst := path.Join(repository.ImplPackagePath, "mocks.go")
 		dst, err = filepath.Rel(cli.Impl, dst)
 		if err != nil {
@@ -456,10 +619,55 @@ func generateRepositoryStubFile(
 		})
 	}
 	sort.Slice(templateData.MockDirectives, func(i, j int) bool {
-		return templateData.MockDirectives[i].ImplPackage < templateData.MockDirectives[j].ImplPackage
+		a, b := templateData.MockDirectives[i], templateData.MockDirectives[j]
+		if a.ImplPackage != b.ImplPackage {
+			return a.ImplPackage < b.ImplPackage
+		}
+		if a.Dst != b.Dst {
+			return a.Dst < b.Dst
+		}
+		return a.Src < b.Src
 	})
 
-	templateData.Repositories = repositories
+	// Packages sharing a name are imported under an alias.
+	aliasedImports, err := aliasPackages(fsys, repositories, implPackage)
+	if err != nil {
+		return "", err
+	}
+	framework := config.diFramework()
+	if framework.StubImportsAPI {
+		apiImports, err := aliasPackages(fsys, repositories, apiPackage)
+		if err != nil {
+			return "", err
+		}
+		aliasedImports = append(aliasedImports, apiImports...)
+	}
+
+	// Every profile's registry is declared, even if empty, so that references
+	// to it remain valid.
+	registries := map[string]*Registry{}
+	for _, profile := range profiles {
+		name := framework.Registry(profile)
+		if _, ok := registries[name]; !ok {
+			registries[name] = &Registry{Name: name, Dependencies: profile.Dependencies}
+			templateData.Registries = append(templateData.Registries, registries[name])
+		}
+	}
+	for _, repository := range repositories {
+		// Generic repositories can only be provided once instantiated, which is
+		// left to the caller.
+		if len(repository.TypeParams) > 0 {
+			continue
+		}
+		name := framework.Registry(repository.profile())
+		registry, ok := registries[name]
+		if !ok {
+			registry = &Registry{Name: name, Dependencies: repository.profile().Dependencies}
+			registries[name] = registry
+			templateData.Registries = append(templateData.Registries, registry)
+		}
+		registry.Repositories = append(registry.Repositories, repository)
+	}
 	pkgImport, pkgAlias, err := loadLocalPackage(fsys, nil, packagePath)
 	if err != nil {
 		return "", err
@@ -472,8 +680,9 @@ func generateRepositoryStubFile(
 	imports, err := collectImports(
 		fsys,
 		nil,
-		false,
+		framework.StubImportsAPI,
 		true,
+		aliasedImports,
 		nil,
 		repositories...,
 	)
@@ -481,27 +690,108 @@ func generateRepositoryStubFile(
 		return "", err
 	}
 	templateData.Imports = imports
-	tmpl, err := template.
-		New("repositoryStubFileTemplate").
-		Parse(repositoryStubFileTemplate)
+	stubSrc, err := executeTemplate(templateStub, templateSets[defaultTemplateSet].withFramework().Stub, templateData)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
-	}
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, templateData); err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
+		return "", err
 	}
 	return formatImports(
-		path.Join(packagePath, "repositories.go"),
-		buf.Bytes(),
+		path.Join(packagePath, config.StubFile),
+		[]byte(stubSrc),
 	)
 }
 
+// packageOf returns the name and path of a package of a repository.
+type packageOf func(repository *RepositoryImpl) (name *string, packagePath string)
+
+func implPackage(repository *RepositoryImpl) (*string, string) {
+	return &repository.ImplPackage, repository.ImplPackagePath
+}
+
+func apiPackage(repository *RepositoryImpl) (*string, string) {
+	return &repository.Package, repository.PackagePath
+}
+
+// aliasPackages renames the packages of repositories to the aliases returned by
+// packageAliases, returning the imports of the aliased packages.
+func aliasPackages(fsys fs.FS, repositories []*RepositoryImpl, pkg packageOf) ([]Import, error) {
+	var imports []Import
+	for packagePath, alias := range packageAliases(repositories, pkg) {
+		importPath, _, err := loadLocalPackage(fsys, nil, packagePath)
+		if err != nil {
+			return nil, err
+		}
+		imports = append(imports, Import{Name: alias, Path: importPath})
+		for _, repository := range repositories {
+			if name, repositoryPackagePath := pkg(repository); repositoryPackagePath == packagePath {
+				*name = alias
+			}
+		}
+	}
+	return imports, nil
+}
+
+// packageAliases returns the aliases packages are imported under, keyed by
+// package path. Packages whose name differs from their directory are aliased
+// by name. Packages whose name is shared by another package are aliased by
+// prefixing the name with as many parent directories as are needed to make
+// them unique, e.g. internal/v1/users -> v1usersimpl.
+func packageAliases(repositories []*RepositoryImpl, pkg packageOf) map[string]string {
+	pathsByName := map[string][]string{}
+	for _, repository := range repositories {
+		name, packagePath := pkg(repository)
+		paths := pathsByName[*name]
+		if !slices.Contains(paths, packagePath) {
+			pathsByName[*name] = append(paths, packagePath)
+		}
+	}
+	aliases := map[string]string{}
+	for name, paths := range pathsByName {
+		if len(paths) < 2 {
+			if path.Base(paths[0]) != name {
+				aliases[paths[0]] = name
+			}
+			continue
+		}
+		for depth := 1; ; depth++ {
+			candidates := map[string]string{}
+			unique := true
+			for _, implPackagePath := range paths {
+				parts := strings.Split(path.Dir(implPackagePath), "/")
+				prefix := strings.Join(parts[max(len(parts)-depth, 0):], "")
+				alias := strings.Map(func(r rune) rune {
+					if unicode.IsLetter(r) || unicode.IsDigit(r) {
+						return unicode.ToLower(r)
+					}
+					return -1
+				}, prefix) + name
+				for _, other := range candidates {
+					if other == alias {
+						unique = false
+					}
+				}
+				candidates[implPackagePath] = alias
+			}
+			if unique || depth > strings.Count(paths[0], "/")+1 {
+				for implPackagePath, alias := range candidates {
+					aliases[implPackagePath] = alias
+				}
+				break
+			}
+		}
+	}
+	return aliases
+}
+
+// collectImports returns the imports needed to declare repositories in astFile,
+// which may be nil for new files, that aren't imported yet. The imports used by
+// the new methods of implemented are included, and packages are renamed to the
+// names astFile imports them under.
 func collectImports(
 	fsys fs.FS,
 	astFile *ast.File,
 	importAPI, importImpl bool,
 	extraImports []Import,
+	implemented []*RepositoryImpl,
 	repositories ...*RepositoryImpl,
 ) (allImports []Import, _ error) {
 	usedImports := make(map[string]bool)
@@ -511,7 +801,7 @@ func collectImports(
 			usedImports[path] = true
 		}
 	}
-	allImports = append(allImports, Import{Name: "", Path: "go.uber.org/fx"})
+	allImports = append(allImports, config.diFramework().Imports...)
 	allImports = append(allImports, extraImports...)
 	importRepositories := func(importAPI, importImpl bool) error {
 		for _, repository := range repositories {
@@ -546,17 +836,22 @@ func collectImports(
 
 	for _, repository := range repositories {
 		allImports = append(allImports, repository.Imports...)
+	}
+	for _, repository := range implemented {
 		for _, newMethod := range repository.NewMethods() {
 			if newMethod.Params.HasCtx() {
-				allImports = append(
-					allImports,
-					Import{Name: "", Path: "context"},
-					Import{Name: "", Path: "go.opentelemetry.io/otel"},
-					Import{Name: "", Path: "go.opentelemetry.io/otel/codes"},
-				)
+				allImports = append(allImports, Import{Name: "", Path: "context"})
+			}
+			if repository.Traces(*newMethod) {
+				allImports = append(allImports, repository.tracer().Imports...)
+				if len(repository.SpanParamAttributes(*newMethod)) > 0 || len(repository.SpanResultAttributes(*newMethod)) > 0 {
+					allImports = append(allImports, repository.tracer().AttributeImports...)
+				}
 			}
-			if newMethod.Returns.HasError() {
-				allImports = append(allImports, Import{Name: "", Path: "github.com/rotisserie/eris"})
+			if repository.WrapError(*newMethod) != "" {
+				imp, name := repository.errorWrapper().importIn(astFile)
+				repository.ErrorsPackage = name
+				allImports = append(allImports, imp)
 			}
 		}
 	}
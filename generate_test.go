@@ -66,6 +66,41 @@ func TestNewMethods(t *testing.T) {
 				},
 			},
 		},
+		{
+			"skipped methods are not generated",
+			RepositoryImpl{
+				Repository: Repository{
+					Methods: []*Method{
+						{Ident: "A", Directives: Directives{"skip": ""}},
+						{Ident: "B", Directives: Directives{"notrace": ""}},
+					},
+				},
+			},
+			[]*Method{{Ident: "B", Directives: Directives{"notrace": ""}}},
+		},
+		{
+			"type parameters are not qualified",
+			RepositoryImpl{
+				Repository: Repository{
+					Package:    "api",
+					TypeParams: Params{{Ident: "T", Type: "any"}},
+					Methods: []*Method{
+						{
+							Ident:   "A",
+							Params:  Params{{Ident: "a", Type: "T"}},
+							Returns: Params{{Type: "Mama"}},
+						},
+					},
+				},
+			},
+			[]*Method{
+				{
+					Ident:   "A",
+					Params:  Params{{Ident: "a", Type: "T"}},
+					Returns: Params{{Type: "api.Mama"}},
+				},
+			},
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			require := require.New(t)
@@ -76,6 +111,40 @@ func TestNewMethods(t *testing.T) {
 	}
 }
 
+func TestQualifyType(t *testing.T) {
+	repository := Repository{
+		Package:    "api",
+		TypeParams: Params{{Ident: "T", Type: "any"}},
+	}
+	for _, test := range []struct {
+		have   string
+		expect string
+	}{
+		{"", ""},
+		{"User", "api.User"},
+		{"string", "string"},
+		{"error", "error"},
+		{"T", "T"},
+		{"context.Context", "context.Context"},
+		{"*User", "*api.User"},
+		{"[]User", "[]api.User"},
+		{"[4]*User", "[4]*api.User"},
+		{"map[ID]User", "map[api.ID]api.User"},
+		{"chan<- User", "chan<- api.User"},
+		{"func(User, int) (T, error)", "func(api.User, int) (T, error)"},
+		{"func(user User) error", "func(user api.User) error"},
+		{"...User", "...api.User"},
+		{"Store[User, T]", "api.Store[api.User, T]"},
+		{"entity.Store[User]", "entity.Store[api.User]"},
+		{"struct{ User User }", "struct{ User api.User }"},
+		{"interface{ Get() User }", "interface{ Get() api.User }"},
+	} {
+		t.Run(test.have, func(t *testing.T) {
+			require.Equal(t, test.expect, repository.qualifyType(test.have))
+		})
+	}
+}
+
 func TestParamsHas(t *testing.T) {
 	require := require.New(t)
 	noCtxErr := Params{
@@ -105,31 +174,31 @@ func TestParamsSrc(t *testing.T) {
 		{
 			"single param with ident and type",
 			Params{
-				{"a", "int"},
+				{Ident: "a", Type: "int"},
 			},
 			"a int",
 		},
 		{
 			"multiple params with ident and type",
 			Params{
-				{"a", "int"},
-				{"ok", "bool"},
+				{Ident: "a", Type: "int"},
+				{Ident: "ok", Type: "bool"},
 			},
 			"a int, ok bool",
 		},
 		{
 			"ctx is qualified",
 			Params{
-				{"", "context.Context"},
-				{"", "int"},
+				{Ident: "", Type: "context.Context"},
+				{Ident: "", Type: "int"},
 			},
 			"ctx context.Context, _ int",
 		},
 		{
 			"ctx is qualified and other parmas retain name",
 			Params{
-				{"_", "context.Context"},
-				{"yoyo", "int"},
+				{Ident: "_", Type: "context.Context"},
+				{Ident: "yoyo", Type: "int"},
 			},
 			"ctx context.Context, yoyo int",
 		},
@@ -144,14 +213,32 @@ func TestParamsSrc(t *testing.T) {
 		{
 			"adjacent types are grouped",
 			Params{
-				{"yep", "bool"},
-				{"nope", "bool"},
-				{"one", "int"},
-				{"two", "int"},
-				{"three", "int"},
+				{Ident: "yep", Type: "bool"},
+				{Ident: "nope", Type: "bool"},
+				{Ident: "one", Type: "int"},
+				{Ident: "two", Type: "int"},
+				{Ident: "three", Type: "int"},
 			},
 			"yep, nope bool, one, two, three int",
 		},
+		{
+			"unnamed params of the same type are not grouped",
+			Params{
+				{Type: "int"},
+				{Type: "int"},
+			},
+			"int, int",
+		},
+		{
+			"composite types are preserved",
+			Params{
+				{Ident: "cb", Type: "func(a, b int) error"},
+				{Ident: "m", Type: "map[Key]struct{ A, B int }"},
+				{Ident: "p", Type: "Pair[A, B]"},
+				{Ident: "xs", Type: "...string"},
+			},
+			"cb func(a, b int) error, m map[Key]struct{ A, B int }, p Pair[A, B], xs ...string",
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			require := require.New(t)
@@ -170,7 +257,7 @@ func TestReturnsSrc(t *testing.T) {
 		{
 			"bracketed if named",
 			Params{
-				{"a", "int"},
+				{Ident: "a", Type: "int"},
 			},
 			"(a int)",
 		},
@@ -181,6 +268,14 @@ func TestReturnsSrc(t *testing.T) {
 			},
 			"int",
 		},
+		{
+			"bracketed if multiple",
+			Params{
+				{Type: "int"},
+				{Type: "bool"},
+			},
+			"(int, bool)",
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			require := require.New(t)
@@ -211,6 +306,11 @@ func TestName(t *testing.T) {
 			Repository{Ident: "Repository"},
 			"Repository",
 		},
+		{
+			"profile suffix is trimmed",
+			Repository{Ident: "UserService", Profile: newProfile("Service")},
+			"User",
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			require := require.New(t)
@@ -239,6 +339,18 @@ func TestDependencies(t *testing.T) {
 			"S",
 			"FooS",
 		},
+		{
+			"S is prefixed with impl directive",
+			Repository{Ident: "FooRepository", Directives: Directives{"impl": "postgres"}},
+			"S",
+			"PostgresFooS",
+		},
+		{
+			"S is prefixed with impl directive if Repository",
+			Repository{Ident: "Repository", Directives: Directives{"impl": "postgres"}},
+			"S",
+			"PostgresS",
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			require := require.New(t)
@@ -282,6 +394,11 @@ func TestImplName(t *testing.T) {
 			Repository{Ident: "FooRepository"},
 			"fooRepositoryImpl",
 		},
+		{
+			"impl directive is prefixed",
+			Repository{Ident: "FooRepository", Directives: Directives{"impl": "Postgres"}},
+			"postgresFooRepositoryImpl",
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			require := require.New(t)
@@ -398,6 +515,94 @@ func TestGenerateMethodImpl(t *testing.T) {
     _ = ctx
     panic("TODO: implement foo.Repository.A")
   }
+`,
+		},
+		{
+			"otel span is not created if method has notrace directive",
+			Input{
+				Repository{
+					Package: "foo",
+					Ident:   "Repository",
+				},
+				Method{
+					Ident:      "A",
+					Params:     Params{{Type: "context.Context"}},
+					Returns:    Params{{Type: "error"}},
+					Directives: Directives{"notrace": ""},
+				},
+			},
+			`
+  func (r *repositoryImpl) A(ctx context.Context) (err error) {
+    defer func() {
+      if err != nil {
+        err = eris.Wrap(err, "foo.Repository.A")
+      }
+    }()
+    panic("TODO: implement foo.Repository.A")
+  }
+`,
+		},
+		{
+			"otel span is not created if repository has notrace directive",
+			Input{
+				Repository{
+					Package:    "foo",
+					Ident:      "Repository",
+					Directives: Directives{"notrace": ""},
+				},
+				Method{
+					Ident:  "A",
+					Params: Params{{Type: "context.Context"}},
+				},
+			},
+			`
+  func (r *repositoryImpl) A(ctx context.Context) {
+    panic("TODO: implement foo.Repository.A")
+  }
+`,
+		},
+		{
+			"doc comment is carried to the implementation",
+			Input{
+				Repository{
+					Package: "foo",
+					Ident:   "Repository",
+				},
+				Method{
+					Ident: "A",
+					Doc:   []string{"// A does a thing."},
+				},
+			},
+			`
+  // A implements foo.Repository.A.
+  //
+  // A does a thing.
+  func (r *repositoryImpl) A() {
+    panic("TODO: implement foo.Repository.A")
+  }
+`,
+		},
+		{
+			"generic receiver is instantiated with type parameters",
+			Input{
+				Repository{
+					Package: "foo",
+					Ident:   "StoreRepository",
+					TypeParams: Params{
+						{Ident: "T", Type: "any"},
+						{Ident: "ID", Type: "comparable"},
+					},
+				},
+				Method{
+					Ident:   "Get",
+					Params:  Params{{Ident: "id", Type: "ID"}},
+					Returns: Params{{Type: "T"}},
+				},
+			},
+			`
+  func (r *storeRepositoryImpl[T, ID]) Get(id ID) T {
+    panic("TODO: implement foo.StoreRepository.Get")
+  }
 `,
 		},
 	} {
@@ -472,6 +677,105 @@ func NewBarRepository(deps BarDependencies) foo.BarRepository {
 type barRepositoryImpl struct {
   BarDependencies
 }
+`,
+		},
+		{
+			"doc comment is carried to the implementation",
+			Repository{
+				Package: "foo",
+				Ident:   "Repository",
+				Doc:     []string{"// Repository stores things.", "// It is documented."},
+			},
+			`
+type Dependencies struct {
+  fx.In
+	// Add dependencies here
+}
+
+var Options = fx.Options(
+	fx.Provide(
+		NewRepository,
+	),
+)
+
+func NewRepository(deps Dependencies) foo.Repository {
+	return &repositoryImpl{
+    Dependencies: deps,
+	}
+}
+
+// repositoryImpl implements foo.Repository.
+//
+// Repository stores things.
+// It is documented.
+type repositoryImpl struct {
+  Dependencies
+}
+`,
+		},
+		{
+			"generic repository declares type parameters",
+			Repository{
+				Package: "foo",
+				Ident:   "StoreRepository",
+				TypeParams: Params{
+					{Ident: "T", Type: "Entity"},
+					{Ident: "ID", Type: "comparable"},
+				},
+			},
+			`
+type StoreDependencies struct {
+  fx.In
+	// Add dependencies here
+}
+
+func StoreOptions[T foo.Entity, ID comparable]() fx.Option {
+	return fx.Options(
+		fx.Provide(
+			NewStoreRepository[T, ID],
+		),
+	)
+}
+
+func NewStoreRepository[T foo.Entity, ID comparable](deps StoreDependencies) foo.StoreRepository[T, ID] {
+	return &storeRepositoryImpl[T, ID]{
+    StoreDependencies: deps,
+	}
+}
+
+type storeRepositoryImpl[T foo.Entity, ID comparable] struct {
+  StoreDependencies
+}
+`,
+		},
+		{
+			"profile names declarations",
+			Repository{
+				Package: "foo",
+				Ident:   "UserService",
+				Profile: newProfile("Service"),
+			},
+			`
+type UserServiceDependencies struct {
+  fx.In
+	// Add dependencies here
+}
+
+var UserServiceOptions = fx.Options(
+	fx.Provide(
+		NewUserService,
+	),
+)
+
+func NewUserService(deps UserServiceDependencies) foo.UserService {
+	return &userServiceImpl{
+    UserServiceDependencies: deps,
+	}
+}
+
+type userServiceImpl struct {
+  UserServiceDependencies
+}
 `,
 		},
 	} {
@@ -513,8 +817,9 @@ var x errors.Something
 			"internal/one.go",
 			[]*RepositoryImpl{
 				{
-					Repository:  Repository{Package: "api"},
-					ImplPackage: "internal",
+					Repository:   Repository{Package: "api"},
+					ImplPackage:  "internal",
+					ImplFilename: "one.go",
 				},
 			},
 			`// Some comment
@@ -557,8 +862,9 @@ var x errors.Something
 							{Ident: "A"},
 						},
 					},
-					ImplPackage: "internal",
-					ImplMethods: []string{},
+					ImplPackage:  "internal",
+					ImplFilename: "one.go",
+					ImplMethods:  []string{},
 				},
 			},
 			`package internal
@@ -604,8 +910,9 @@ var x errors.Something
 							{Ident: "A"},
 						},
 					},
-					ImplPackage: "internal",
-					ImplMethods: []string{},
+					ImplPackage:  "internal",
+					ImplFilename: "one.go",
+					ImplMethods:  []string{},
 				},
 			},
 			`package internal
@@ -648,8 +955,9 @@ var x something.Something
 							{Path: "somewhere/something"},
 						},
 					},
-					ImplPackage: "internal",
-					ImplMethods: []string{},
+					ImplPackage:  "internal",
+					ImplFilename: "one.go",
+					ImplMethods:  []string{},
 				},
 			},
 			`package internal
@@ -659,6 +967,57 @@ import "somewhere/something"
 type repositoryImpl struct{}
 
 var x something.Something
+`,
+		},
+		{
+			"composite types are qualified with the existing api import alias",
+			map[string]string{
+				"go.mod": `
+        module example
+        `,
+				"internal/one.go": `package internal
+
+import apiv1 "example/api"
+
+type repositoryImpl struct{}
+
+var _ apiv1.Repository = (*repositoryImpl)(nil)
+`,
+			},
+			"internal/one.go",
+			[]*RepositoryImpl{
+				{
+					Repository: Repository{
+						Package:     "api",
+						PackagePath: "api",
+						Ident:       "Repository",
+						Methods: []*Method{
+							{
+								Ident: "List",
+								Params: Params{
+									{Ident: "filter", Type: "map[string]Filter"},
+									{Ident: "each", Type: "func(*User) error"},
+								},
+								Returns: Params{{Type: "[]*User"}, {Type: "chan User"}},
+							},
+						},
+					},
+					ImplPackage:  "internal",
+					ImplFilename: "one.go",
+					ImplMethods:  []string{},
+				},
+			},
+			`package internal
+
+import apiv1 "example/api"
+
+type repositoryImpl struct{}
+
+var _ apiv1.Repository = (*repositoryImpl)(nil)
+
+func (r *repositoryImpl) List(filter map[string]apiv1.Filter, each func(*apiv1.User) error) ([]*apiv1.User, chan apiv1.User) {
+	panic("TODO: implement apiv1.Repository.List")
+}
 `,
 		},
 		{
@@ -680,9 +1039,10 @@ var hoyminoy string
 						PackagePath: "api",
 						Ident:       "Repository",
 					},
-					IsNew:       true,
-					ImplPackage: "internal",
-					ImplMethods: []string{},
+					IsNew:        true,
+					ImplPackage:  "internal",
+					ImplFilename: "one.go",
+					ImplMethods:  []string{},
 				},
 			},
 			`package internal
@@ -746,9 +1106,10 @@ func NewRepository(deps Dependencies) hahafucku.Repository {
 						PackagePath: "api",
 						Ident:       "Repository",
 					},
-					IsNew:       false,
-					ImplPackage: "internal",
-					ImplMethods: []string{},
+					IsNew:        false,
+					ImplPackage:  "internal",
+					ImplFilename: "one.go",
+					ImplMethods:  []string{},
 				},
 				{
 					Repository: Repository{
@@ -756,9 +1117,10 @@ func NewRepository(deps Dependencies) hahafucku.Repository {
 						PackagePath: "api",
 						Ident:       "BRepository",
 					},
-					IsNew:       true,
-					ImplPackage: "internal",
-					ImplMethods: []string{},
+					IsNew:        true,
+					ImplPackage:  "internal",
+					ImplFilename: "one.go",
+					ImplMethods:  []string{},
 				},
 			},
 			`package internal
@@ -812,9 +1174,10 @@ type bRepositoryImpl struct {
 						PackagePath: "api",
 						Ident:       "Repository",
 					},
-					IsNew:       true,
-					ImplPackage: "internal",
-					ImplMethods: []string{},
+					IsNew:        true,
+					ImplPackage:  "internal",
+					ImplFilename: "one.go",
+					ImplMethods:  []string{},
 				},
 			},
 			`// This file will be automatically regenerated based on the API. Any repository implementations
@@ -983,6 +1346,70 @@ var Repositories = fx.Options(
 	jesseimpl.Options,
 	waltuhimpl.Options,
 )
+`,
+		},
+		{
+			"repositories from packages sharing a name are aliased and ordered",
+			[]*RepositoryImpl{
+				{
+					Repository: Repository{
+						Ident:       "UserRepository",
+						Package:     "users",
+						PackagePath: "api/v2/users",
+					},
+					ImplPackage:     "usersimpl",
+					ImplPackagePath: "internal/v2/users",
+				},
+				{
+					Repository: Repository{
+						Ident:       "Repository",
+						Package:     "jesse",
+						PackagePath: "api/jesse",
+					},
+					ImplPackage:     "jesseimpl",
+					ImplPackagePath: "internal/jesse",
+				},
+				{
+					Repository: Repository{
+						Ident:       "UserRepository",
+						Package:     "users",
+						PackagePath: "api/v1/users",
+					},
+					ImplPackage:     "usersimpl",
+					ImplPackagePath: "internal/v1/users",
+				},
+				{
+					Repository: Repository{
+						Ident:       "Repository",
+						Package:     "users",
+						PackagePath: "api/v1/users",
+					},
+					ImplPackage:     "usersimpl",
+					ImplPackagePath: "internal/v1/users",
+				},
+			},
+			`// DO NOT MODIFY
+// This file will be automatically regenerated based on the API.
+package internal
+
+//go:generate moq -out=jesse/mocks.go -pkg=jesseimpl -rm -skip-ensure ../api/jesse Repository
+//go:generate moq -out=v1/users/mocks.go -pkg=usersimpl -rm -skip-ensure ../api/v1/users Repository UserRepository
+//go:generate moq -out=v2/users/mocks.go -pkg=usersimpl -rm -skip-ensure ../api/v2/users UserRepository
+
+import (
+	jesseimpl "example/internal/jesse"
+	v1usersimpl "example/internal/v1/users"
+	v2usersimpl "example/internal/v2/users"
+
+	"go.uber.org/fx"
+)
+
+var Repositories = fx.Options(
+	jesseimpl.Options,
+	v1usersimpl.Options,
+	v1usersimpl.UserOptions,
+	v2usersimpl.UserOptions,
+)
 `,
 		},
 	} {
@@ -1006,3 +1433,116 @@ var Repositories = fx.Options(
 		})
 	}
 }
+
+func TestGenerateRepositoryStubFileRegistries(t *testing.T) {
+	require := require.New(t)
+	cli.Impl = "internal"
+	service := newProfile("Service")
+	defer func(original []*Profile) { profiles = original }(profiles)
+	profiles = []*Profile{defaultProfile, service, newProfile("Client")}
+	fsys := fstest.MapFS{
+		"go.mod": &fstest.MapFile{Data: []byte("module example"), Mode: 0644},
+	}
+	got, err := generateRepositoryStubFile(
+		fsys,
+		"internal",
+		&RepositoryImpl{
+			Repository: Repository{
+				Ident:       "UserService",
+				Package:     "users",
+				PackagePath: "api/users",
+				Profile:     service,
+			},
+			ImplPackage:     "usersimpl",
+			ImplPackagePath: "internal/usersimpl",
+		},
+		&RepositoryImpl{
+			Repository: Repository{
+				Ident:       "UserRepository",
+				Package:     "users",
+				PackagePath: "api/users",
+			},
+			ImplPackage:     "usersimpl",
+			ImplPackagePath: "internal/usersimpl",
+		},
+	)
+	require.NoError(err)
+	require.Equal(`// DO NOT MODIFY
+// This file will be automatically regenerated based on the API.
+package internal
+
+//go:generate moq -out=usersimpl/mocks.go -pkg=usersimpl -rm -skip-ensure ../api/users UserRepository UserService
+
+import (
+	"example/internal/usersimpl"
+
+	"go.uber.org/fx"
+)
+
+var Repositories = fx.Options(
+	usersimpl.UserOptions,
+)
+
+var Services = fx.Options(
+	usersimpl.UserServiceOptions,
+)
+
+var Clients = fx.Options()
+`, got)
+}
+
+func TestGenerateRepositoryStubFileConfig(t *testing.T) {
+	require := require.New(t)
+	cli.Impl = "internal"
+	cli.API = "api"
+	defer func(original *Config) { config = original }(config)
+	config = defaultConfig()
+	config.StubFile = "services.go"
+	config.Packages = map[string]PackageConfig{
+		"users": {Mocks: mocksNone},
+	}
+	fsys := fstest.MapFS{
+		"go.mod": &fstest.MapFile{Data: []byte("module example"), Mode: 0644},
+	}
+	got, err := generateRepositoryStubFile(
+		fsys,
+		"internal",
+		&RepositoryImpl{
+			Repository: Repository{
+				Ident:       "UserRepository",
+				Package:     "users",
+				PackagePath: "api/users",
+			},
+			ImplPackage:     "usersimpl",
+			ImplPackagePath: "internal/users",
+		},
+		&RepositoryImpl{
+			Repository: Repository{
+				Ident:       "MovieRepository",
+				Package:     "movies",
+				PackagePath: "api/movies",
+			},
+			ImplPackage:     "moviesimpl",
+			ImplPackagePath: "internal/movies",
+		},
+	)
+	require.NoError(err)
+	require.Equal(`// DO NOT MODIFY
+// This file will be automatically regenerated based on the API.
+package internal
+
+//go:generate moq -out=movies/mocks.go -pkg=moviesimpl -rm -skip-ensure ../api/movies MovieRepository
+
+import (
+	moviesimpl "example/internal/movies"
+	usersimpl "example/internal/users"
+
+	"go.uber.org/fx"
+)
+
+var Repositories = fx.Options(
+	moviesimpl.MovieOptions,
+	usersimpl.UserOptions,
+)
+`, got)
+}
@@ -5,15 +5,15 @@ go 1.22.1
 require (
 	github.com/alecthomas/kong v0.9.0
 	github.com/lmittmann/tint v1.0.5
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/smacker/go-tree-sitter v0.0.0-20240625050157-a31a98a7c0f6
 	github.com/stretchr/testify v1.7.4
-	golang.org/x/mod v0.19.0
-	golang.org/x/tools v0.13.0
+	golang.org/x/mod v0.22.0
+	golang.org/x/tools v0.28.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/sys v0.12.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/sync v0.10.0 // indirect
 )
@@ -0,0 +1,85 @@
+package main
+
+// lifecycleHook returns the function of an fx hook calling the first method of
+// the repository named in names, or "" if there is none. Methods can accept a
+// context.Context and return an error, which the hook passes through.
+func (r Repository) lifecycleHook(names []string) string {
+	for _, name := range names {
+		for _, method := range r.Methods {
+			if method.Ident != name {
+				continue
+			}
+			if hook, ok := hookFunc(method, r.contextType()); ok {
+				return hook
+			}
+		}
+	}
+	return ""
+}
+
+// contextType returns the type context.Context is referred to by in the API,
+// which is imported into the implementation as the API imports it.
+func (r Repository) contextType() string {
+	for _, method := range r.Methods {
+		for _, param := range method.Params {
+			if param.role() == roleContext {
+				return param.Type
+			}
+		}
+	}
+	for _, imp := range r.Imports {
+		if imp.Path == "context" && imp.Name != "" && imp.Name != "_" && imp.Name != "." {
+			return imp.Name + ".Context"
+		}
+	}
+	return "context.Context"
+}
+
+// hookFunc returns the function calling method from an fx hook, reporting false
+// if its signature can't be called from one. contextType is the type
+// context.Context is referred to by.
+func hookFunc(method *Method, contextType string) (string, bool) {
+	var args string
+	switch {
+	case len(method.Params) == 0:
+	case len(method.Params) == 1 && method.Params[0].role() == roleContext:
+		args = "ctx"
+	default:
+		return "", false
+	}
+	returnsErr := false
+	switch {
+	case len(method.Returns) == 0:
+	case len(method.Returns) == 1 && method.Returns.HasError():
+		returnsErr = true
+	default:
+		return "", false
+	}
+	call := "impl." + method.Ident + "(" + args + ")"
+	switch {
+	case args != "" && returnsErr && method.Returns[0].role() == roleError:
+		return "impl." + method.Ident, true
+	case returnsErr:
+		return "func(ctx " + contextType + ") error {\n\t\t\treturn " + call + "\n\t\t}", true
+	default:
+		return "func(ctx " + contextType + ") error {\n\t\t\t" + call + "\n\t\t\treturn nil\n\t\t}", true
+	}
+}
+
+// OnStartHook returns the function of the fx OnStart hook of the
+// implementation, or "" if it has none.
+func (r Repository) OnStartHook() string {
+	return r.lifecycleHook(config.Lifecycle.OnStart)
+}
+
+// OnStopHook returns the function of the fx OnStop hook of the implementation,
+// or "" if it has none.
+func (r Repository) OnStopHook() string {
+	return r.lifecycleHook(config.Lifecycle.OnStop)
+}
+
+// HasLifecycle reports whether the constructor of the implementation registers
+// fx lifecycle hooks.
+func (r Repository) HasLifecycle() bool {
+	return r.OnStartHook() != "" || r.OnStopHook() != ""
+}
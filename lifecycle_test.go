@@ -0,0 +1,196 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleHooks(t *testing.T) {
+	ctx := &Param{Ident: "ctx", Type: "context.Context"}
+	err := &Param{Type: "error"}
+	for _, test := range []struct {
+		name        string
+		methods     []*Method
+		expectStart string
+		expectStop  string
+	}{
+		{
+			"no lifecycle methods",
+			[]*Method{{Ident: "Get", Params: Params{ctx}, Returns: Params{err}}},
+			"",
+			"",
+		},
+		{
+			"hook signatures are passed as is",
+			[]*Method{
+				{Ident: "Start", Params: Params{ctx}, Returns: Params{err}},
+				{Ident: "Stop", Params: Params{ctx}, Returns: Params{err}},
+			},
+			"impl.Start",
+			"impl.Stop",
+		},
+		{
+			"other signatures are wrapped",
+			[]*Method{
+				{Ident: "Start", Params: Params{ctx}},
+				{Ident: "Close", Returns: Params{err}},
+			},
+			"func(ctx context.Context) error {\n\t\t\timpl.Start(ctx)\n\t\t\treturn nil\n\t\t}",
+			"func(ctx context.Context) error {\n\t\t\treturn impl.Close()\n\t\t}",
+		},
+		{
+			"methods are preferred in configured order",
+			[]*Method{
+				{Ident: "Close", Returns: Params{err}},
+				{Ident: "Stop", Params: Params{ctx}, Returns: Params{err}},
+			},
+			"",
+			"impl.Stop",
+		},
+		{
+			"incompatible signatures are ignored",
+			[]*Method{
+				{Ident: "Start", Params: Params{{Ident: "addr", Type: "string"}}, Returns: Params{err}},
+				{Ident: "Stop", Params: Params{ctx}, Returns: Params{{Type: "bool"}}},
+				{Ident: "Close", Returns: Params{err}},
+			},
+			"",
+			"func(ctx context.Context) error {\n\t\t\treturn impl.Close()\n\t\t}",
+		},
+		{
+			"error-like results are returned as errors",
+			[]*Method{
+				{Ident: "Start", Params: Params{ctx}, Returns: Params{{Type: "app.Error", Role: roleErrorLike}}},
+			},
+			"func(ctx context.Context) error {\n\t\t\treturn impl.Start(ctx)\n\t\t}",
+			"",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			repository := Repository{Methods: test.methods}
+			require.Equal(test.expectStart, repository.OnStartHook())
+			require.Equal(test.expectStop, repository.OnStopHook())
+			require.Equal(test.expectStart != "" || test.expectStop != "", repository.HasLifecycle())
+		})
+	}
+}
+
+func TestLifecycleHooksKeepContextImportName(t *testing.T) {
+	require := require.New(t)
+	closer := &Method{Ident: "Close", Returns: Params{{Type: "error"}}}
+	imports := []Import{{Name: "stdctx", Path: "context"}}
+
+	repository := Repository{
+		Methods: []*Method{
+			{Ident: "Start", Params: Params{{Ident: "ctx", Type: "stdctx.Context", Role: roleContext}}},
+			closer,
+		},
+		Imports: imports,
+	}
+	require.Equal("func(ctx stdctx.Context) error {\n\t\t\timpl.Start(ctx)\n\t\t\treturn nil\n\t\t}", repository.OnStartHook())
+	require.Equal("func(ctx stdctx.Context) error {\n\t\t\treturn impl.Close()\n\t\t}", repository.OnStopHook())
+
+	// Without context parameters, the name is read from the imports.
+	repository = Repository{Methods: []*Method{closer}, Imports: imports}
+	require.Equal("func(ctx stdctx.Context) error {\n\t\t\treturn impl.Close()\n\t\t}", repository.OnStopHook())
+}
+
+func TestGenerateRepositoryImplWithLifecycle(t *testing.T) {
+	repository := Repository{
+		Package: "users",
+		Ident:   "UserRepository",
+		Methods: []*Method{
+			{Ident: "Start", Params: Params{{Ident: "ctx", Type: "context.Context"}}, Returns: Params{{Type: "error"}}},
+			{Ident: "Close", Returns: Params{{Type: "error"}}},
+		},
+	}
+	for _, test := range []struct {
+		name   string
+		di     string
+		expect string
+	}{
+		{
+			"fx",
+			diFx,
+			`
+type UserDependencies struct {
+  fx.In
+	// Add dependencies here
+}
+
+var UserOptions = fx.Options(
+	fx.Provide(
+		NewUserRepository,
+	),
+)
+
+func NewUserRepository(lc fx.Lifecycle, deps UserDependencies) users.UserRepository {
+	impl := &userRepositoryImpl{
+    UserDependencies: deps,
+	}
+	lc.Append(fx.Hook{
+		OnStart: impl.Start,
+		OnStop: func(ctx context.Context) error {
+			return impl.Close()
+		},
+	})
+	return impl
+}
+
+type userRepositoryImpl struct {
+  UserDependencies
+}
+`,
+		},
+		{
+			"fx module",
+			diFxModule,
+			`
+type UserDependencies struct {
+	fx.In
+	// Add dependencies here
+}
+
+var UserOptions = fx.Options(
+	fx.Provide(
+		fx.Annotate(
+			NewUserRepository,
+			fx.As(fx.Self()),
+			fx.As(new(users.UserRepository)),
+		),
+	),
+)
+
+func NewUserRepository(lc fx.Lifecycle, deps UserDependencies) *userRepositoryImpl {
+	impl := &userRepositoryImpl{
+		deps: deps,
+	}
+	lc.Append(fx.Hook{
+		OnStart: impl.Start,
+		OnStop: func(ctx context.Context) error {
+			return impl.Close()
+		},
+	})
+	return impl
+}
+
+type userRepositoryImpl struct {
+	deps UserDependencies
+}
+`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			defer func(original *Config) { config = original }(config)
+			config = defaultConfig()
+			config.DI = test.di
+
+			got, err := generateRepositoryImpl(repository)
+			require.NoError(err)
+			require.Equal(test.expect, got)
+		})
+	}
+}
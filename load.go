@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"golang.org/x/tools/go/gcexportdata"
+	"golang.org/x/tools/go/packages"
+)
+
+// loadAPIPackages type-checks every package under apiDir. The returned map is a
+// mapping of package directories, relative to root, to the loaded package.
+func loadAPIPackages(
+	ctx context.Context,
+	root, apiDir string,
+) (map[string]*packages.Package, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if absRoot, err = filepath.EvalSymlinks(absRoot); err != nil {
+		return nil, err
+	}
+	cfg := &packages.Config{
+		Context: ctx,
+		Dir:     absRoot,
+		Fset:    fset,
+		Mode: packages.NeedName |
+			packages.NeedFiles |
+			packages.NeedSyntax |
+			packages.NeedImports |
+			packages.NeedTypes |
+			packages.NeedTypesInfo,
+	}
+	// Dependencies are imported from export data, unless it was written by a
+	// toolchain newer than go/packages can read, in which case they are
+	// type-checked from source.
+	if !exportDataReadable(ctx, absRoot) {
+		slog.Debug("export data is unreadable, type-checking dependencies from source")
+		cfg.Mode |= packages.NeedDeps
+	}
+	pattern := "./" + path.Join(path.Clean(apiDir), "...")
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages matching %s: %w", pattern, err)
+	}
+	loaded := make(map[string]*packages.Package, len(pkgs))
+	for _, pkg := range pkgs {
+		if len(pkg.GoFiles) == 0 {
+			continue
+		}
+		dir, err := filepath.EvalSymlinks(filepath.Dir(pkg.GoFiles[0]))
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(absRoot, dir)
+		if err != nil {
+			return nil, err
+		}
+		loaded[filepath.ToSlash(rel)] = pkg
+	}
+	return loaded, nil
+}
+
+// exportDataReadable reports whether the export data of the packages imported
+// from dir can be read.
+func exportDataReadable(ctx context.Context, dir string) bool {
+	pkgs, err := packages.Load(&packages.Config{
+		Context: ctx,
+		Dir:     dir,
+		Mode:    packages.NeedName | packages.NeedExportFile,
+	}, "errors")
+	if err != nil || len(pkgs) != 1 || pkgs[0].ExportFile == "" {
+		return false
+	}
+	f, err := os.Open(pkgs[0].ExportFile)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	r, err := gcexportdata.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return false
+	}
+	_, err = gcexportdata.Read(r, token.NewFileSet(), make(map[string]*types.Package), pkgs[0].PkgPath)
+	return err == nil
+}
+
+// loadRepositoriesForPackage returns the repositories declared in packagePath.
+// Packages that were type-checked without errors are read from their type
+// information, otherwise the package is parsed syntactically.
+func loadRepositoriesForPackage(
+	ctx context.Context,
+	fsys fs.FS,
+	loaded map[string]*packages.Package,
+	packagePath string,
+	packageFiles []string,
+) ([]*Repository, error) {
+	pkg, ok := loaded[packagePath]
+	if ok && len(pkg.Errors) == 0 {
+		return repositoriesFromPackage(pkg, packagePath)
+	}
+	if ok {
+		slog.Debug(
+			"Package does not type-check, falling back to syntactic parsing",
+			slog.String("api_path", packagePath),
+			slog.Any("errors", pkg.Errors),
+		)
+	}
+	return parseRepositoriesForPackage(ctx, fsys, packagePath, packageFiles)
+}
+
+// repositoriesFromPackage extracts the repositories declared in a type-checked package.
+func repositoriesFromPackage(
+	pkg *packages.Package,
+	packagePath string,
+) (repos []*Repository, err error) {
+	files := make([]*ast.File, len(pkg.Syntax))
+	copy(files, pkg.Syntax)
+	sort.Slice(files, func(i, j int) bool {
+		return pkg.Fset.File(files[i].Pos()).Name() < pkg.Fset.File(files[j].Pos()).Name()
+	})
+	methodFields, err := interfaceMethodFields(pkg)
+	if err != nil {
+		return nil, err
+	}
+	roles := newTypeRoles(pkg.Types)
+	repos = []*Repository{}
+	for _, file := range files {
+		filename := filepath.Base(pkg.Fset.File(file.Pos()).Name())
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				profile := matchProfile(typeSpec.Name.Name)
+				if profile == nil {
+					continue
+				}
+				doc := typeSpec.Doc
+				if doc == nil && !genDecl.Lparen.IsValid() {
+					doc = genDecl.Doc
+				}
+				directives, docLines := parseDirectives(commentLines(doc))
+				if directives.Has("skip") {
+					continue
+				}
+				obj, ok := pkg.TypesInfo.Defs[typeSpec.Name].(*types.TypeName)
+				if !ok {
+					continue
+				}
+				if _, ok := obj.Type().Underlying().(*types.Interface); !ok {
+					continue
+				}
+				q := newTypeQualifier(pkg.Types, file)
+				repo := &Repository{
+					Package:     pkg.Name,
+					PackagePath: packagePath,
+					Filename:    filename,
+					Ident:       typeSpec.Name.Name,
+					Profile:     profile,
+					Directives:  directives,
+					Doc:         docLines,
+				}
+				if named, ok := obj.Type().(*types.Named); ok {
+					for i := 0; i < named.TypeParams().Len(); i++ {
+						tp := named.TypeParams().At(i)
+						repo.TypeParams = append(repo.TypeParams, &Param{
+							Ident: tp.Obj().Name(),
+							Type:  types.TypeString(tp.Constraint(), q.qualify),
+						})
+					}
+				}
+				var embeds []types.Type
+				for _, field := range typeSpec.Type.(*ast.InterfaceType).Methods.List {
+					if len(field.Names) == 0 {
+						repo.Embeds = append(repo.Embeds, types.ExprString(field.Type))
+						embeds = append(embeds, pkg.TypesInfo.TypeOf(field.Type))
+						continue
+					}
+					for _, name := range field.Names {
+						fn, ok := pkg.TypesInfo.Defs[name].(*types.Func)
+						if !ok {
+							continue
+						}
+						repo.Methods = append(repo.Methods, methodFromField(pkg, fn, methodFields[fn], q, roles))
+					}
+				}
+				for _, embedded := range embeds {
+					for _, fn := range embeddedMethods(embedded) {
+						method := methodFromField(pkg, fn, methodFields[fn.Origin()], q, roles)
+						repo.Methods = mergeMethods(repo.Methods, []*Method{method})
+					}
+				}
+				repo.Imports = q.imports
+				repos = append(repos, repo)
+			}
+		}
+	}
+	return repos, nil
+}
+
+// methodField is the declaration of an interface method.
+type methodField struct {
+	file  *ast.File
+	field *ast.Field
+	// src is the source of file.
+	src []byte
+}
+
+// interfaceMethodFields returns the declarations of every interface method
+// declared in pkg.
+func interfaceMethodFields(pkg *packages.Package) (map[*types.Func]methodField, error) {
+	fields := make(map[*types.Func]methodField)
+	for _, file := range pkg.Syntax {
+		src, err := os.ReadFile(pkg.Fset.File(file.Pos()).Name())
+		if err != nil {
+			return nil, err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			iface, ok := n.(*ast.InterfaceType)
+			if !ok {
+				return true
+			}
+			for _, field := range iface.Methods.List {
+				for _, name := range field.Names {
+					if fn, ok := pkg.TypesInfo.Defs[name].(*types.Func); ok {
+						fields[fn] = methodField{file: file, field: field, src: src}
+					}
+				}
+			}
+			return true
+		})
+	}
+	return fields, nil
+}
+
+// methodFromField returns the method fn, reading its directives and doc, and
+// those of its parameters, from its declaration. Unless fn is instantiated from
+// a generic interface, the types of its parameters and results are also read
+// from its declaration, so that they are spelled as in the source.
+func methodFromField(
+	pkg *packages.Package,
+	fn *types.Func,
+	decl methodField,
+	q *typeQualifier,
+	roles *typeRoles,
+) *Method {
+	method := methodFromFunc(fn, q, roles)
+	if decl.field == nil {
+		return method
+	}
+	method.Directives, method.Doc = parseDirectives(commentLines(decl.field.Doc))
+	funcType, ok := decl.field.Type.(*ast.FuncType)
+	if !ok {
+		return method
+	}
+	if fn.Origin() == fn {
+		applyParamTypes(method.Params, paramTypes(pkg, decl, funcType.Params, q))
+		applyParamTypes(method.Returns, paramTypes(pkg, decl, funcType.Results, q))
+	}
+	applyParamDirectives(method.Params, paramDirectives(pkg.Fset, decl.file, funcType.Params))
+	applyParamDirectives(method.Returns, paramDirectives(pkg.Fset, decl.file, funcType.Results))
+	return method
+}
+
+// paramTypes returns the source of the type of each parameter of a parameter
+// list, one per parameter name.
+func paramTypes(pkg *packages.Package, decl methodField, params *ast.FieldList, q *typeQualifier) (srcs []string) {
+	if params == nil {
+		return nil
+	}
+	for _, field := range params.List {
+		typ := typeSrc(pkg, decl, field.Type, q)
+		for range max(len(field.Names), 1) {
+			srcs = append(srcs, typ)
+		}
+	}
+	return srcs
+}
+
+func applyParamTypes(params Params, srcs []string) {
+	if len(srcs) != len(params) {
+		return
+	}
+	for i, param := range params {
+		param.Type = srcs[i]
+	}
+}
+
+// typeSrc returns the source of the type expression expr declared in decl,
+// referring to the packages of the identifiers it uses the way q qualifies
+// them. Types are printed from the source rather than from their type
+// information so that they round-trip exactly, e.g. func(a, b int) isn't
+// expanded to func(a int, b int).
+func typeSrc(pkg *packages.Package, decl methodField, expr ast.Expr, q *typeQualifier) string {
+	file := pkg.Fset.File(expr.Pos())
+	start := file.Offset(expr.Pos())
+	offset := func(pos token.Pos) uint32 {
+		return uint32(file.Offset(pos) - start)
+	}
+	var edits []srcEdit
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			x, ok := n.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if _, ok := pkg.TypesInfo.Uses[x].(*types.PkgName); !ok {
+				return true
+			}
+			obj := pkg.TypesInfo.Uses[n.Sel]
+			if obj == nil || obj.Pkg() == nil {
+				return false
+			}
+			switch name := q.qualify(obj.Pkg()); name {
+			case "":
+				edits = append(edits, srcEdit{start: offset(x.Pos()), end: offset(n.Sel.Pos())})
+			case x.Name:
+			default:
+				edits = append(edits, srcEdit{start: offset(x.Pos()), end: offset(x.End()), text: name})
+			}
+			return false
+		case *ast.Ident:
+			// Package-level objects of other packages referred to without a
+			// qualifier are dot-imported.
+			obj := pkg.TypesInfo.Uses[n]
+			if obj == nil || obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+				return true
+			}
+			if name := q.qualify(obj.Pkg()); name != "" {
+				edits = append(edits, srcEdit{start: offset(n.Pos()), end: offset(n.Pos()), text: name + "."})
+			}
+		}
+		return true
+	})
+	return string(applyEdits(decl.src[start:file.Offset(expr.End())], edits))
+}
+
+// paramDirectives returns the directives in the comments preceding each
+// parameter of a parameter list, one per parameter name.
+func paramDirectives(fset *token.FileSet, file *ast.File, params *ast.FieldList) (directives []Directives) {
+	// An unparenthesised result can't be preceded by a comment.
+	if params == nil || !params.Opening.IsValid() {
+		return nil
+	}
+	prevEnd := params.Opening
+	for _, field := range params.List {
+		var comments []string
+		for _, group := range file.Comments {
+			if group.Pos() < prevEnd || group.End() > field.Pos() {
+				continue
+			}
+			// A comment sharing a line with the preceding parameter belongs to it.
+			if fset.Position(group.Pos()).Line == fset.Position(prevEnd).Line {
+				continue
+			}
+			comments = append(comments, commentLines(group)...)
+		}
+		fieldDirectives, _ := parseDirectives(comments)
+		for range max(len(field.Names), 1) {
+			directives = append(directives, fieldDirectives)
+		}
+		prevEnd = field.End()
+	}
+	return directives
+}
+
+func applyParamDirectives(params Params, directives []Directives) {
+	for i, param := range params {
+		if i < len(directives) && len(directives[i]) > 0 {
+			param.Directives = directives[i]
+		}
+	}
+}
+
+// commentLines returns the raw lines of a comment group.
+func commentLines(doc *ast.CommentGroup) []string {
+	if doc == nil {
+		return nil
+	}
+	lines := make([]string, len(doc.List))
+	for i, comment := range doc.List {
+		lines[i] = comment.Text
+	}
+	return lines
+}
+
+// embeddedMethods returns the methods of an embedded interface. Methods declared
+// directly on the interface come first in source order, followed by the methods
+// of the interfaces it embeds.
+func embeddedMethods(t types.Type) (methods []*types.Func) {
+	iface, ok := t.Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+	for i := 0; i < iface.NumExplicitMethods(); i++ {
+		methods = append(methods, iface.ExplicitMethod(i))
+	}
+	sort.SliceStable(methods, func(i, j int) bool {
+		return methods[i].Pos() < methods[j].Pos()
+	})
+	for i := 0; i < iface.NumEmbeddeds(); i++ {
+		methods = append(methods, embeddedMethods(iface.EmbeddedType(i))...)
+	}
+	return methods
+}
+
+// methodFromFunc returns the method fn, resolving the roles of its parameters
+// and results from their types.
+func methodFromFunc(fn *types.Func, q *typeQualifier, roles *typeRoles) *Method {
+	sig := fn.Type().(*types.Signature)
+	method := &Method{Ident: fn.Name()}
+	for i := 0; i < sig.Params().Len(); i++ {
+		v := sig.Params().At(i)
+		param := &Param{Ident: v.Name(), Type: types.TypeString(v.Type(), q.qualify)}
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			param.Type = "..." + types.TypeString(v.Type().(*types.Slice).Elem(), q.qualify)
+		} else {
+			param.setRole(roles.role(v.Type()))
+		}
+		method.Params = append(method.Params, param)
+	}
+	for i := 0; i < sig.Results().Len(); i++ {
+		v := sig.Results().At(i)
+		result := &Param{Ident: v.Name(), Type: types.TypeString(v.Type(), q.qualify)}
+		result.setRole(roles.role(v.Type()))
+		method.Returns = append(method.Returns, result)
+	}
+	return method
+}
+
+// typeQualifier qualifies types the way they are referred to in a given file,
+// recording the imports that the qualified types depend on.
+type typeQualifier struct {
+	pkg     *types.Package
+	names   map[string]string
+	seen    map[string]bool
+	imports []Import
+}
+
+func newTypeQualifier(pkg *types.Package, file *ast.File) *typeQualifier {
+	q := &typeQualifier{
+		pkg:   pkg,
+		names: make(map[string]string),
+		seen:  make(map[string]bool),
+	}
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil {
+			q.names[path] = imp.Name.Name
+		}
+	}
+	return q
+}
+
+func (q *typeQualifier) qualify(pkg *types.Package) string {
+	if pkg == q.pkg {
+		return ""
+	}
+	imp := Import{Path: pkg.Path()}
+	name := pkg.Name()
+	// Dot and blank imports can't be referred to by name, so the package name is used instead.
+	if alias := q.names[pkg.Path()]; alias != "" && alias != "." && alias != "_" {
+		imp.Name = alias
+		name = alias
+	}
+	if !q.seen[imp.Path] {
+		q.seen[imp.Path] = true
+		q.imports = append(q.imports, imp)
+	}
+	return name
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	}
+	return root
+}
+
+func TestLoadRepositoriesForPackage(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		files       map[string]string
+		packagePath string
+		expect      []*Repository
+	}{
+		{
+			"types are resolved through aliased, dot and local imports",
+			map[string]string{
+				"go.mod": "module example\n\ngo 1.22\n",
+				"api/entity/entity.go": `
+        package entity
+
+        type User struct{}
+        `,
+				"api/users/users.go": `
+        package users
+
+        import (
+          stdctx "context"
+          . "example/api/entity"
+          "time"
+        )
+
+        type Filter struct{}
+
+        type UserRepository interface {
+          Get(ctx stdctx.Context, id string) (*User, error)
+          List(ctx stdctx.Context, filter Filter, since time.Time) ([]User, error)
+          Tag(ids ...string)
+        }
+        `,
+			},
+			"api/users",
+			[]*Repository{
+				{
+					Package: "users",
+					Ident:   "UserRepository",
+					Methods: []*Method{
+						{
+							Ident: "Get",
+							Params: Params{
+								{Ident: "ctx", Type: "stdctx.Context", Role: roleContext},
+								{Ident: "id", Type: "string"},
+							},
+							Returns: Params{
+								{Type: "*entity.User"},
+								{Type: "error"},
+							},
+						},
+						{
+							Ident: "List",
+							Params: Params{
+								{Ident: "ctx", Type: "stdctx.Context", Role: roleContext},
+								{Ident: "filter", Type: "Filter"},
+								{Ident: "since", Type: "time.Time"},
+							},
+							Returns: Params{
+								{Type: "[]entity.User"},
+								{Type: "error"},
+							},
+						},
+						{
+							Ident: "Tag",
+							Params: Params{
+								{Ident: "ids", Type: "...string"},
+							},
+						},
+					},
+					Imports: []Import{
+						{Name: "stdctx", Path: "context"},
+						{Path: "example/api/entity"},
+						{Path: "time"},
+					},
+				},
+			},
+		},
+		{
+			"embedded interfaces are flattened",
+			map[string]string{
+				"go.mod": "module example\n\ngo 1.22\n",
+				"api/crud/crud.go": `
+        package crud
+
+        type Entity struct{}
+
+        type Reader interface {
+          Get(id string) (Entity, error)
+        }
+        `,
+				"api/users/users.go": `
+        package users
+
+        import (
+          "context"
+          "io"
+
+          "example/api/crud"
+        )
+
+        type Banner interface {
+          Ban(ctx context.Context, id string) error
+        }
+
+        type UserRepository interface {
+          crud.Reader
+          Banner
+          io.Closer
+          Get(id string) (crud.Entity, error)
+        }
+        `,
+			},
+			"api/users",
+			[]*Repository{
+				{
+					Package: "users",
+					Ident:   "UserRepository",
+					Methods: []*Method{
+						{
+							Ident:   "Get",
+							Params:  Params{{Ident: "id", Type: "string"}},
+							Returns: Params{{Type: "crud.Entity"}, {Type: "error"}},
+						},
+						{
+							Ident: "Ban",
+							Params: Params{
+								{Ident: "ctx", Type: "context.Context"},
+								{Ident: "id", Type: "string"},
+							},
+							Returns: Params{{Type: "error"}},
+						},
+						{
+							Ident:   "Close",
+							Returns: Params{{Type: "error"}},
+						},
+					},
+					Imports: []Import{
+						{Path: "example/api/crud"},
+						{Path: "context"},
+					},
+				},
+			},
+		},
+		{
+			"generic repositories and instantiated embeddings",
+			map[string]string{
+				"go.mod": "module example\n\ngo 1.22\n",
+				"api/store/store.go": `
+        package store
+
+        type User struct{}
+
+        type StoreRepository[T any, ID comparable] interface {
+          Get(id ID) (T, error)
+        }
+
+        type UserRepository interface {
+          StoreRepository[User, string]
+        }
+        `,
+			},
+			"api/store",
+			[]*Repository{
+				{
+					Package: "store",
+					Ident:   "StoreRepository",
+					TypeParams: Params{
+						{Ident: "T", Type: "any"},
+						{Ident: "ID", Type: "comparable"},
+					},
+					Methods: []*Method{
+						{
+							Ident:   "Get",
+							Params:  Params{{Ident: "id", Type: "ID"}},
+							Returns: Params{{Type: "T"}, {Type: "error"}},
+						},
+					},
+				},
+				{
+					Package: "store",
+					Ident:   "UserRepository",
+					Methods: []*Method{
+						{
+							Ident:   "Get",
+							Params:  Params{{Ident: "id", Type: "string"}},
+							Returns: Params{{Type: "User"}, {Type: "error"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			"directives are read from doc comments",
+			map[string]string{
+				"go.mod": "module example\n\ngo 1.22\n",
+				"api/users/users.go": `
+        package users
+
+        type BaseRepository interface {
+          // Ping checks the connection.
+          //implgen:notrace
+          Ping()
+        }
+
+        //implgen:skip
+        type SkippedRepository interface {}
+
+        type (
+          // UserRepository stores users.
+          //
+          //implgen:impl=postgres
+          UserRepository interface {
+            BaseRepository
+            Get(id string) error // trailing comments are ignored
+
+            //implgen:skip
+            Delete(id string) error
+          }
+        )
+        `,
+			},
+			"api/users",
+			[]*Repository{
+				{
+					Package: "users",
+					Ident:   "BaseRepository",
+					Methods: []*Method{
+						{
+							Ident:      "Ping",
+							Directives: Directives{"notrace": ""},
+							Doc:        []string{"// Ping checks the connection."},
+						},
+					},
+				},
+				{
+					Package:    "users",
+					Ident:      "UserRepository",
+					Directives: Directives{"impl": "postgres"},
+					Doc:        []string{"// UserRepository stores users."},
+					Methods: []*Method{
+						{
+							Ident:   "Get",
+							Params:  Params{{Ident: "id", Type: "string"}},
+							Returns: Params{{Type: "error"}},
+						},
+						{
+							Ident:      "Delete",
+							Params:     Params{{Ident: "id", Type: "string"}},
+							Returns:    Params{{Type: "error"}},
+							Directives: Directives{"skip": ""},
+						},
+						{
+							Ident:      "Ping",
+							Directives: Directives{"notrace": ""},
+							Doc:        []string{"// Ping checks the connection."},
+						},
+					},
+				},
+			},
+		},
+		{
+			"parameter directives are read from leading comments",
+			map[string]string{
+				"go.mod": "module example\n\ngo 1.22\n",
+				"api/users/users.go": `
+        package users
+
+        // UserRepository stores users.
+        type UserRepository interface {
+          Login(
+            username string, // trailing comments are ignored
+            //implgen:redact
+            password, otp string,
+          ) (
+            //implgen:redact
+            token string,
+            err error,
+          )
+          Logout(id string) error
+        }
+        `,
+			},
+			"api/users",
+			[]*Repository{
+				{
+					Package: "users",
+					Ident:   "UserRepository",
+					Doc:     []string{"// UserRepository stores users."},
+					Methods: []*Method{
+						{
+							Ident: "Login",
+							Params: Params{
+								{Ident: "username", Type: "string"},
+								{Ident: "password", Type: "string", Directives: Directives{"redact": ""}},
+								{Ident: "otp", Type: "string", Directives: Directives{"redact": ""}},
+							},
+							Returns: Params{
+								{Ident: "token", Type: "string", Directives: Directives{"redact": ""}},
+								{Ident: "err", Type: "error"},
+							},
+						},
+						{
+							Ident:   "Logout",
+							Params:  Params{{Ident: "id", Type: "string"}},
+							Returns: Params{{Type: "error"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			"packages that do not compile fall back to syntactic parsing",
+			map[string]string{
+				"go.mod": "module example\n\ngo 1.22\n",
+				"api/broken/broken.go": `
+        package broken
+
+        type BrokenRepository interface {
+          Get(id Missing) error
+        }
+        `,
+			},
+			"api/broken",
+			[]*Repository{
+				{
+					Package: "broken",
+					Ident:   "BrokenRepository",
+					Methods: []*Method{
+						{
+							Ident:   "Get",
+							Params:  Params{{Ident: "id", Type: "Missing"}},
+							Returns: Params{{Type: "error"}},
+						},
+					},
+				},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			ctx := context.Background()
+			root := writeModule(t, test.files)
+			loaded, err := loadAPIPackages(ctx, root, "api")
+			require.NoError(err)
+			apiFiles, err := crawlAPI(os.DirFS(root), "api")
+			require.NoError(err)
+			got, err := loadRepositoriesForPackage(
+				ctx,
+				os.DirFS(root),
+				loaded,
+				test.packagePath,
+				apiFiles[test.packagePath],
+			)
+			require.NoError(err)
+			testRepositories(t, test.expect, got)
+		})
+	}
+}
+
+func TestLoadedTypesMatchSyntacticParsing(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	root := writeModule(t, map[string]string{
+		"go.mod": "module example\n\ngo 1.22\n",
+		"api/users/users.go": `
+package users
+
+import "context"
+
+type UserRepository interface {
+	Ban(ctx context.Context, cb func(a, b int) error) error
+	Pair() map[string]struct{ A, B int }
+	Each(fn func(
+		id string,
+	) bool, ids ...[]string)
+}
+`,
+	})
+	loaded, err := loadAPIPackages(ctx, root, "api")
+	require.NoError(err)
+	typed, err := loadRepositoriesForPackage(ctx, os.DirFS(root), loaded, "api/users", []string{"users.go"})
+	require.NoError(err)
+	parsed, err := loadRepositoriesForPackage(ctx, os.DirFS(root), nil, "api/users", []string{"users.go"})
+	require.NoError(err)
+	require.Len(typed, 1)
+	require.Len(parsed, 1)
+
+	signatures := func(repository *Repository) (signatures []string) {
+		for _, method := range repository.Methods {
+			signatures = append(signatures, method.Ident+"("+method.Params.src()+") "+method.Returns.src())
+		}
+		return signatures
+	}
+	require.Equal([]string{
+		"Ban(ctx context.Context, cb func(a, b int) error) error",
+		"Pair() map[string]struct{ A, B int }",
+		"Each(fn func(\n\t\tid string,\n\t) bool, ids ...[]string) ",
+	}, signatures(typed[0]))
+	require.Equal(signatures(parsed[0]), signatures(typed[0]))
+}
+
+func TestFallbackWarnsAboutUnresolvedEmbeds(t *testing.T) {
+	require := require.New(t)
+	var logs bytes.Buffer
+	defer func(logger *slog.Logger) { slog.SetDefault(logger) }(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	ctx := context.Background()
+	root := writeModule(t, map[string]string{
+		"go.mod": "module example\n\ngo 1.22\n",
+		"api/users/users.go": `
+package users
+
+import "io"
+
+type UserRepository interface {
+	io.Closer
+	Get(id Missing) error
+}
+`,
+	})
+	loaded, err := loadAPIPackages(ctx, root, "api")
+	require.NoError(err)
+	got, err := loadRepositoriesForPackage(ctx, os.DirFS(root), loaded, "api/users", []string{"users.go"})
+	require.NoError(err)
+	require.Len(got, 1)
+	require.Len(got[0].Methods, 1)
+	require.Contains(logs.String(), "level=WARN")
+	require.Contains(logs.String(), "interface=UserRepository embedded=io.Closer")
+}
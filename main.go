@@ -2,24 +2,37 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"go/token"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path"
 	"runtime/debug"
+	"sort"
 	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/lmittmann/tint"
+	"golang.org/x/tools/go/packages"
 )
 
 var (
 	cli struct {
 		Root    string `type:"path" help:"Root directory to generate the api/impl tree from." default:"."`
-		API     string `type:"string" help:"Directory to API definitions, relative to root." default:"api"`
-		Impl    string `type:"string" help:"Directory to implementation files, relative to root." default:"internal"`
+		API     string `type:"string" help:"Directory to API definitions, relative to root. Defaults to the configuration file, or api."`
+		Impl    string `type:"string" help:"Directory to implementation files, relative to root. Defaults to the configuration file, or internal."`
 		Verbose bool   `help:"Enable verbose logging." short:"v"`
+
+		NoTypeCheck bool     `help:"Parse API definitions syntactically instead of type-checking them." name:"no-typecheck"`
+		Profiles    []string `help:"Interface suffixes to implement, optionally with a pattern matching interface names as SUFFIX=PATTERN. Defaults to the configuration file, or Repository." name:"profile"`
+		Drift       string   `help:"How to handle implementation methods whose signature drifted from the API (${enum})." enum:"report,rewrite" default:"report"`
+		Orphans     string   `help:"How to handle implementation methods that no longer exist on the API (${enum})." enum:"report,deprecate,move" default:"report"`
+		DryRun      bool     `help:"Print a diff of the files that would be created or changed instead of writing them." name:"dry-run"`
+		Check       bool     `help:"Fail without writing anything if any generated code is out of date."`
+		KeepGoing   bool     `help:"Keep generating the remaining packages after a package fails, reporting every failure." name:"keep-going"`
+		ExitCode    bool     `help:"Exit with status 2 if any file was created or changed." name:"exit-code"`
 	}
 	fset = token.NewFileSet()
 )
@@ -38,23 +51,69 @@ func main() {
 		logOpts.AddSource = true
 	}
 	logger := slog.New(
-		tint.NewHandler(os.Stdout, logOpts),
+		tint.NewHandler(os.Stderr, logOpts),
 	)
 	slog.SetDefault(logger)
-	if err := run(); err != nil {
-		logger.Error(
-			"Failed to run implgen",
-			slog.Any("error", err),
-		)
+	cfg, err := loadConfig(cli.Root)
+	if err != nil {
+		logger.Error("Failed to load configuration", slog.Any("error", err))
+		os.Exit(exitError)
+	}
+	config = cfg
+	// Flags take precedence over the configuration file.
+	if cli.API == "" {
+		cli.API = config.API
+	}
+	if cli.Impl == "" {
+		cli.Impl = config.Impl
+	}
+	profileConfigs := config.Profiles
+	if len(cli.Profiles) > 0 {
+		profileConfigs = nil
+		for _, spec := range cli.Profiles {
+			profileConfigs = append(profileConfigs, parseProfileConfig(spec))
+		}
+	}
+	profiles = nil
+	for _, profileConfig := range profileConfigs {
+		profile, err := profileConfig.profile()
+		if err != nil {
+			logger.Error("Invalid profile", slog.Any("error", err))
+			os.Exit(exitError)
+		}
+		profiles = append(profiles, profile)
+	}
+	changed, err := run()
+	switch {
+	case errors.Is(err, errStale):
+		logger.Error("Generated code is out of date, run implgen to regenerate it")
+	case err != nil:
+		for _, err := range unjoinErrors(err) {
+			logger.Error(
+				"Failed to run implgen",
+				slog.Any("error", err),
+			)
+		}
 		if cli.Verbose {
 			debug.PrintStack()
 		}
 	}
+	os.Exit(exitCode(changed, err))
 }
 
-func run() error {
+// run generates the implementations of every API package, reporting whether
+// any file was (or, in dry-run mode, would be) created or changed.
+func run() (changed bool, err error) {
 	ctx := context.Background()
-	fsys := os.DirFS(cli.Root)
+	var fsys WriteFS = newDiskFS(cli.Root)
+	var overlay *overlayFS
+	if cli.DryRun || cli.Check {
+		overlay = newOverlayFS(fsys)
+		fsys = overlay
+	}
+	if err := loadTemplateOverrides(fsys); err != nil {
+		return false, fmt.Errorf("failed to load templates: %w", err)
+	}
 	slog.Debug(
 		"Crawling API directory",
 		slog.String("root", cli.Root),
@@ -63,130 +122,272 @@ func run() error {
 	)
 	apiFiles, err := crawlAPI(fsys, cli.API)
 	if err != nil {
-		return fmt.Errorf("failed to walk API directory: %w", err)
+		return false, &ParseError{Path: cli.API, Err: fmt.Errorf("failed to walk API directory: %w", err)}
+	}
+	var loaded map[string]*packages.Package
+	if !cli.NoTypeCheck {
+		loaded, err = loadAPIPackages(ctx, cli.Root, cli.API)
+		if err != nil {
+			slog.Debug(
+				"Failed to type-check API packages, falling back to syntactic parsing",
+				slog.Any("error", err),
+			)
+		}
+	}
+	apiPackagePaths := make([]string, 0, len(apiFiles))
+	for apiPackagePath := range apiFiles {
+		apiPackagePaths = append(apiPackagePaths, apiPackagePath)
+	}
+	sort.Strings(apiPackagePaths)
+	if err := config.checkPackages(apiPackagePaths); err != nil {
+		return false, &ConfigError{Path: configFilename, Err: err}
 	}
-	for apiPackagePath, packageFiles := range apiFiles {
-		repos, err := parseRepositoriesForPackage(
+	var (
+		allRepImpls []*RepositoryImpl
+		errs        []error
+		stats       runStats
+	)
+	for _, apiPackagePath := range apiPackagePaths {
+		repImpls, err := runPackage(
 			ctx,
 			fsys,
+			loaded,
 			apiPackagePath,
-			packageFiles,
+			apiFiles[apiPackagePath],
+			&stats,
 		)
 		if err != nil {
-			return fmt.Errorf("failed to parse repositories in %s: %w", apiPackagePath, err)
-		}
-		if len(repos) == 0 {
+			if !cli.KeepGoing {
+				return stats.changed, err
+			}
+			errs = append(errs, err)
 			continue
 		}
-		slog.Debug(
-			"Parsed repositories",
-			slog.String("api_path", apiPackagePath),
-			slog.Int("count", len(repos)),
-		)
-		implPackagePath, err := computeImplPackagePath(
-			cli.API,
-			cli.Impl,
-			apiPackagePath,
-		)
+		allRepImpls = append(allRepImpls, repImpls...)
+	}
+	if len(errs) > 0 {
+		// The stub file would lose the implementations of the failed
+		// packages, so it is left untouched.
+		return stats.changed, errors.Join(errs...)
+	}
+	if len(allRepImpls) > 0 {
+		// The stub file aggregates the implementations of every API package.
+		stubPath := path.Join(cli.Impl, config.StubFile)
+		stubSrc, err := generateRepositoryStubFile(fsys, cli.Impl, allRepImpls...)
 		if err != nil {
-			return fmt.Errorf(
-				"failed to compute implementation package path associated with API %s: %w",
-				apiPackagePath,
-				err,
-			)
+			return stats.changed, &GenerateError{Path: stubPath, Err: err}
 		}
-		repImpls, err := parseRepositoryImpls(
-			ctx,
-			fsys,
-			implPackagePath,
-			repos,
-		)
+		written, err := writeFile(fsys, stubPath, []byte(stubSrc))
 		if err != nil {
-			return fmt.Errorf("failed to parse repository implementations: %w", err)
+			return stats.changed, err
+		}
+		stats.changed = stats.changed || written
+		slog.Debug("Generated repository stub file", slog.Int("repositories", len(allRepImpls)))
+	}
+	if overlay == nil {
+		return stats.changed, nil
+	}
+	changedFiles, err := overlay.changedFiles()
+	if err != nil {
+		return false, fmt.Errorf("failed to diff generated files: %w", err)
+	}
+	if cli.DryRun {
+		if err := overlay.writeDiffs(os.Stdout, changedFiles, isTerminal(os.Stdout)); err != nil {
+			return false, fmt.Errorf("failed to diff generated files: %w", err)
 		}
-		for filename, impls := range groupByImplFilename(repImpls) {
-			implPath := path.Join(implPackagePath, filename)
-			_, statErr := os.Stat(implPath)
-			exists := statErr == nil
-			data, err := generateRepositoryImplsForFile(fsys, implPath, impls)
+		// Only the diff is written to stdout, so that it can be piped to patch.
+		fmt.Fprintf(
+			os.Stderr,
+			"%d file(s) would change: %d new implementation(s), %d new method(s)\n",
+			len(changedFiles),
+			stats.newImpls,
+			stats.newMethods,
+		)
+	}
+	if cli.Check {
+		return len(changedFiles) > 0, reportStale(staleItems(allRepImpls), changedFiles)
+	}
+	return len(changedFiles) > 0, nil
+}
+
+// runStats accumulates what a run generated.
+type runStats struct {
+	changed              bool
+	newImpls, newMethods int
+}
+
+// runPackage generates the implementations of the API package at apiPackagePath,
+// returning the implementations of its repositories.
+func runPackage(
+	ctx context.Context,
+	fsys WriteFS,
+	loaded map[string]*packages.Package,
+	apiPackagePath string,
+	packageFiles []string,
+	stats *runStats,
+) ([]*RepositoryImpl, error) {
+	repos, err := loadRepositoriesForPackage(
+		ctx,
+		fsys,
+		loaded,
+		apiPackagePath,
+		packageFiles,
+	)
+	if err != nil {
+		return nil, &ParseError{Path: apiPackagePath, Err: err}
+	}
+	if len(repos) == 0 {
+		return nil, nil
+	}
+	slog.Debug(
+		"Parsed repositories",
+		slog.String("api_path", apiPackagePath),
+		slog.Int("count", len(repos)),
+	)
+	implPackagePath, err := computeImplPackagePath(
+		cli.API,
+		cli.Impl,
+		apiPackagePath,
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to compute implementation package path associated with API %s: %w",
+			apiPackagePath,
+			err,
+		)
+	}
+	repImpls, err := parseRepositoryImpls(
+		ctx,
+		fsys,
+		implPackagePath,
+		repos,
+	)
+	if err != nil {
+		return nil, &ParseError{Path: implPackagePath, Err: err}
+	}
+	reportDrift(implPackagePath, repImpls)
+	reportOrphans(implPackagePath, repImpls)
+	if cli.Orphans == orphansMove {
+		// Orphaned methods are copied before they are removed from the
+		// files declaring them.
+		for filename, impls := range groupByOrphanedFilename(repImpls) {
+			orphanedPath := path.Join(implPackagePath, orphanedFilename(filename))
+			data, err := generateOrphanedFile(fsys, implPackagePath, filename, impls)
 			if err != nil {
-				return fmt.Errorf("failed to generate implementation file: %w", err)
+				return nil, &GenerateError{Path: orphanedPath, Err: err}
 			}
 			if data == "" {
 				continue
 			}
-			if err := os.MkdirAll(
-				path.Dir(implPath),
-				0755,
-			); err != nil {
-				return fmt.Errorf("failed to create directory for implementation file at %s: %w", implPath, err)
-			}
-			if err := os.WriteFile(
-				implPath,
-				[]byte(data),
-				0644,
-			); err != nil {
-				return fmt.Errorf("failed to write implementation file at %s: %w", implPath, err)
+			written, err := writeFile(fsys, orphanedPath, []byte(data))
+			if err != nil {
+				return nil, err
 			}
+			stats.changed = stats.changed || written
+		}
+	}
+	for filename, impls := range groupByImplFilename(repImpls) {
+		implPath := path.Join(implPackagePath, filename)
+		_, statErr := fs.Stat(fsys, implPath)
+		exists := statErr == nil
+		data, err := generateRepositoryImplsForFile(fsys, implPath, impls)
+		if err != nil {
+			return nil, &GenerateError{Path: implPath, Err: err}
+		}
+		if data == "" {
+			continue
+		}
+		written, err := writeFile(fsys, implPath, []byte(data))
+		if err != nil {
+			return nil, err
+		}
+		stats.changed = stats.changed || written
 
-			var nNewImpls, nNewMethods int
-			for _, impl := range impls {
-				if impl.IsNew {
-					nNewImpls++
-				}
-				nNewMethods += len(impl.NewMethods())
-			}
-			if nNewImpls == 0 && nNewMethods == 0 {
+		var nNewImpls, nNewMethods int
+		for _, impl := range impls {
+			if impl.ImplFilename != filename {
 				continue
 			}
-			var logMsg string
-			if exists {
-				logMsg = "Updated implementation file"
-			} else {
-				logMsg = "Created implementation file"
+			if impl.IsNew {
+				nNewImpls++
 			}
-			slog.Debug(
-				logMsg,
-				slog.String("api_path", apiPackagePath),
-				slog.String("impl_path", implPath),
-				slog.Int("new_implementations", nNewImpls),
-				slog.Int("new_methods", nNewMethods),
-			)
+			nNewMethods += len(impl.NewMethods())
 		}
-		stubSrc, err := generateRepositoryStubFile(fsys, cli.Impl, repImpls...)
-		if err != nil {
-			return fmt.Errorf("failed to generate repository stub file: %w", err)
+		if nNewImpls == 0 && nNewMethods == 0 {
+			continue
 		}
-		if err := os.WriteFile(
-			path.Join(cli.Impl, "repositories.go"),
-			[]byte(stubSrc),
-			0644,
-		); err != nil {
-			return fmt.Errorf("failed to write repository stub file: %w", err)
+		stats.newImpls += nNewImpls
+		stats.newMethods += nNewMethods
+		var logMsg string
+		if exists {
+			logMsg = "Updated implementation file"
+		} else {
+			logMsg = "Created implementation file"
 		}
-		slog.Debug("Generated repository stub file")
+		slog.Debug(
+			logMsg,
+			slog.String("api_path", apiPackagePath),
+			slog.String("impl_path", implPath),
+			slog.Int("new_implementations", nNewImpls),
+			slog.Int("new_methods", nNewMethods),
+		)
 	}
-	return nil
+	return repImpls, nil
 }
 
+// groupByPackage groups repositories by the path of their API package.
 func groupByPackage(repositories []*RepositoryImpl) map[string][]*RepositoryImpl {
 	grouped := make(map[string][]*RepositoryImpl)
 	for _, repository := range repositories {
-		grouped[repository.Package] = append(
-			grouped[repository.Package],
+		grouped[repository.PackagePath] = append(
+			grouped[repository.PackagePath],
 			repository,
 		)
 	}
 	return grouped
 }
 
+// groupByImplFilename groups repositories by the implementation files they
+// generate code in. When rewriting drifted methods or deprecating or moving
+// orphaned methods, this includes the files declaring those methods, so a
+// repository may be in several groups.
 func groupByImplFilename(repositories []*RepositoryImpl) map[string][]*RepositoryImpl {
 	grouped := make(map[string][]*RepositoryImpl)
+	add := func(filename string, repository *RepositoryImpl) {
+		for _, existing := range grouped[filename] {
+			if existing == repository {
+				return
+			}
+		}
+		grouped[filename] = append(grouped[filename], repository)
+	}
 	for _, repository := range repositories {
-		grouped[repository.ImplFilename] = append(
-			grouped[repository.ImplFilename],
-			repository,
-		)
+		add(repository.ImplFilename, repository)
+		if cli.Drift == driftRewrite {
+			for _, drift := range repository.Drifted {
+				add(drift.Impl.Filename, repository)
+			}
+		}
+		if cli.Orphans != orphansReport {
+			for _, orphan := range repository.Orphaned {
+				add(orphan.Filename, repository)
+			}
+		}
+	}
+	return grouped
+}
+
+// groupByOrphanedFilename groups repositories by the implementation files
+// declaring their orphaned methods.
+func groupByOrphanedFilename(repositories []*RepositoryImpl) map[string][]*RepositoryImpl {
+	grouped := make(map[string][]*RepositoryImpl)
+	for _, repository := range repositories {
+		for _, orphan := range repository.Orphaned {
+			impls := grouped[orphan.Filename]
+			if len(impls) == 0 || impls[len(impls)-1] != repository {
+				grouped[orphan.Filename] = append(impls, repository)
+			}
+		}
 	}
 	return grouped
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/token"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// orphansReport only reports orphaned methods.
+	orphansReport = "report"
+	// orphansDeprecate marks orphaned methods as deprecated.
+	orphansDeprecate = "deprecate"
+	// orphansMove moves orphaned methods into an _orphaned.go file next to the
+	// file declaring them.
+	orphansMove = "move"
+
+	orphanedFileSuffix = "_orphaned.go"
+)
+
+// orphanedMethods returns the exported methods declared on the implementation
+// that are not declared by the API. Unexported methods are assumed to be helpers,
+// and methods that were already moved to an orphaned file are ignored.
+func (r RepositoryImpl) orphanedMethods() (orphaned []*ImplMethod) {
+	for _, impl := range r.ImplSignatures {
+		if !token.IsExported(impl.Ident) || strings.HasSuffix(impl.Filename, orphanedFileSuffix) {
+			continue
+		}
+		found := false
+		for _, method := range r.Methods {
+			if method.Ident == impl.Ident {
+				found = true
+				break
+			}
+		}
+		if !found {
+			orphaned = append(orphaned, impl)
+		}
+	}
+	return orphaned
+}
+
+// deprecated reports whether the doc comment of m marks it as deprecated.
+func (m ImplMethod) deprecated() bool {
+	for _, line := range m.Doc {
+		if strings.HasPrefix(line, "// Deprecated:") {
+			return true
+		}
+	}
+	return false
+}
+
+// orphanedFilename returns the file orphaned methods declared in filename are moved to.
+func orphanedFilename(filename string) string {
+	return strings.TrimSuffix(filename, ".go") + orphanedFileSuffix
+}
+
+// orphanEdits returns the edits deprecating or removing every orphaned method
+// declared in filename, depending on the orphans mode.
+func orphanEdits(filename string, repositories []*RepositoryImpl) (edits []srcEdit) {
+	for _, repository := range repositories {
+		for _, orphan := range repository.Orphaned {
+			if orphan.Filename != filename {
+				continue
+			}
+			switch cli.Orphans {
+			case orphansDeprecate:
+				if orphan.deprecated() {
+					continue
+				}
+				comment := "// Deprecated: removed from " + repository.QualifiedName() + ".\n"
+				if len(orphan.Doc) > 0 {
+					comment = "//\n" + comment
+				}
+				// Insert the comment at the end of the doc comment, directly
+				// before the declaration.
+				edits = append(edits, srcEdit{start: orphan.Start, end: orphan.Start, text: comment})
+			case orphansMove:
+				edits = append(edits, srcEdit{start: orphan.DocStart, end: orphan.End})
+			}
+		}
+	}
+	return edits
+}
+
+// generateOrphanedFile generates the file that the orphaned methods declared in
+// filename are moved to, appending them to the file if it already exists.
+func generateOrphanedFile(
+	fsys fs.FS,
+	implPackagePath string,
+	filename string,
+	repositories []*RepositoryImpl,
+) (string, error) {
+	var methods []*ImplMethod
+	for _, repository := range repositories {
+		for _, orphan := range repository.Orphaned {
+			if orphan.Filename == filename {
+				methods = append(methods, orphan)
+			}
+		}
+	}
+	if len(methods) == 0 {
+		return "", nil
+	}
+	originalSrc, err := readFile(fsys, path.Join(implPackagePath, filename))
+	if err != nil {
+		return "", err
+	}
+	orphanedPath := path.Join(implPackagePath, orphanedFilename(filename))
+	orphanedSrc, err := readFile(fsys, orphanedPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	// Unused imports are removed when formatting.
+	imports, err := parseImports(originalSrc)
+	if err != nil {
+		return "", err
+	}
+	var (
+		src     bytes.Buffer
+		scanner *bufio.Scanner
+	)
+	if orphanedSrc == nil {
+		fmt.Fprintf(&src, `// Methods removed from the API, moved here for review.
+
+package %s
+`, repositories[0].ImplPackage)
+	} else {
+		// Imports must precede the methods already moved to the file, so they
+		// are added after the package clause.
+		existing, err := parseImports(orphanedSrc)
+		if err != nil {
+			return "", err
+		}
+		imports = slices.DeleteFunc(imports, func(imp Import) bool {
+			return slices.Contains(existing, imp)
+		})
+		scanner = bufio.NewScanner(bytes.NewReader(orphanedSrc))
+		for scanner.Scan() {
+			line := scanner.Text()
+			src.WriteString(line + "\n")
+			if strings.HasPrefix(line, "package") {
+				break
+			}
+		}
+	}
+	for _, imp := range imports {
+		src.WriteString("import ")
+		if imp.Name != "" {
+			src.WriteString(imp.Name + " ")
+		}
+		src.WriteString(strconv.Quote(imp.Path) + "\n")
+	}
+	if scanner != nil {
+		for scanner.Scan() {
+			src.WriteString(scanner.Text() + "\n")
+		}
+	}
+	for _, method := range methods {
+		if int(method.End) > len(originalSrc) {
+			continue
+		}
+		src.WriteString("\n")
+		src.Write(originalSrc[method.DocStart:method.End])
+		src.WriteString("\n")
+	}
+	return formatImports(orphanedPath, src.Bytes())
+}
+
+// reportOrphans logs every orphaned method of the implementations in implPackagePath.
+func reportOrphans(implPackagePath string, repositories []*RepositoryImpl) {
+	for _, repository := range repositories {
+		for _, orphan := range repository.Orphaned {
+			attrs := []any{
+				slog.String("method", repository.QualifiedName()+"."+orphan.Ident),
+				slog.String("location", path.Join(implPackagePath, orphan.Filename)+":"+strconv.Itoa(orphan.Line)),
+			}
+			switch cli.Orphans {
+			case orphansDeprecate:
+				if orphan.deprecated() {
+					continue
+				}
+				slog.Info("Deprecating method removed from the API", attrs...)
+			case orphansMove:
+				attrs = append(attrs, slog.String("destination", path.Join(implPackagePath, orphanedFilename(orphan.Filename))))
+				slog.Info("Moving method removed from the API", attrs...)
+			default:
+				slog.Warn("Implementation method no longer exists on the API", attrs...)
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrphanedMethods(t *testing.T) {
+	require := require.New(t)
+	impl := RepositoryImpl{
+		Repository: Repository{
+			Methods: []*Method{{Ident: "A"}},
+		},
+		ImplSignatures: []*ImplMethod{
+			{Ident: "A", Filename: "a_impl.go"},
+			{Ident: "B", Filename: "a_impl.go"},
+			{Ident: "helper", Filename: "a_impl.go"},
+			{Ident: "C", Filename: "a_impl_orphaned.go"},
+		},
+	}
+	orphaned := impl.orphanedMethods()
+	require.Len(orphaned, 1)
+	require.Equal("B", orphaned[0].Ident)
+}
+
+func TestHandleOrphanedMethods(t *testing.T) {
+	implSrc := `package internal
+
+import (
+	"context"
+	"strings"
+)
+
+type repositoryImpl struct{}
+
+func (r *repositoryImpl) A(ctx context.Context) {}
+
+// B does things.
+func (r *repositoryImpl) B(s string) string {
+	return strings.ToUpper(s)
+}
+
+func (r *repositoryImpl) C() {}
+`
+	for _, test := range []struct {
+		name     string
+		orphans  string
+		expect   string
+		existing string
+		orphaned string
+	}{
+		{
+			"report leaves methods untouched",
+			orphansReport,
+			implSrc,
+			"",
+			"",
+		},
+		{
+			"deprecate marks methods as deprecated",
+			orphansDeprecate,
+			`package internal
+
+import (
+	"context"
+	"strings"
+)
+
+type repositoryImpl struct{}
+
+func (r *repositoryImpl) A(ctx context.Context) {}
+
+// B does things.
+//
+// Deprecated: removed from api.Repository.
+func (r *repositoryImpl) B(s string) string {
+	return strings.ToUpper(s)
+}
+
+// Deprecated: removed from api.Repository.
+func (r *repositoryImpl) C() {}
+`,
+			"",
+			"",
+		},
+		{
+			"move moves methods to orphaned file",
+			orphansMove,
+			`package internal
+
+import (
+	"context"
+)
+
+type repositoryImpl struct{}
+
+func (r *repositoryImpl) A(ctx context.Context) {}
+`,
+			"",
+			`// Methods removed from the API, moved here for review.
+
+package internal
+
+import "strings"
+
+// B does things.
+func (r *repositoryImpl) B(s string) string {
+	return strings.ToUpper(s)
+}
+
+func (r *repositoryImpl) C() {}
+`,
+		},
+		{
+			"move appends methods to existing orphaned file",
+			orphansMove,
+			`package internal
+
+import (
+	"context"
+)
+
+type repositoryImpl struct{}
+
+func (r *repositoryImpl) A(ctx context.Context) {}
+`,
+			`// Methods removed from the API, moved here for review.
+
+package internal
+
+import "fmt"
+
+func (r *repositoryImpl) D() {
+	fmt.Println("D")
+}
+`,
+			`// Methods removed from the API, moved here for review.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (r *repositoryImpl) D() {
+	fmt.Println("D")
+}
+
+// B does things.
+func (r *repositoryImpl) B(s string) string {
+	return strings.ToUpper(s)
+}
+
+func (r *repositoryImpl) C() {}
+`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			defer func(orphans string) { cli.Orphans = orphans }(cli.Orphans)
+			cli.Orphans = test.orphans
+			fsys := fstest.MapFS{
+				"go.mod":          {Data: []byte("module example\n")},
+				"internal/one.go": {Data: []byte(implSrc)},
+			}
+			if test.existing != "" {
+				fsys["internal/one_orphaned.go"] = &fstest.MapFile{Data: []byte(test.existing)}
+			}
+			repos := []*Repository{
+				{
+					Package:     "api",
+					PackagePath: "api",
+					Ident:       "Repository",
+					Methods: []*Method{
+						{Ident: "A", Params: Params{{Ident: "ctx", Type: "context.Context"}}},
+					},
+				},
+			}
+			impls, err := parseRepositoryImpls(context.Background(), fsys, "internal", repos)
+			require.NoError(err)
+			require.Len(impls, 1)
+			require.Len(impls[0].Orphaned, 2)
+
+			orphaned := ""
+			if test.orphans == orphansMove {
+				orphaned, err = generateOrphanedFile(fsys, "internal", "one.go", impls)
+				require.NoError(err)
+			}
+			require.Equal(test.orphaned, orphaned)
+
+			got, err := generateRepositoryImplsForFile(fsys, "internal/one.go", impls)
+			require.NoError(err)
+			require.Equal(test.expect, got)
+		})
+	}
+}
+
+func TestOrphansInOtherFilesKeepImportNames(t *testing.T) {
+	require := require.New(t)
+	defer func(orphans string) { cli.Orphans = orphans }(cli.Orphans)
+	cli.Orphans = orphansDeprecate
+	fsys := fstest.MapFS{
+		"go.mod":             {Data: []byte("module example.com/p1\n")},
+		"api/users/users.go": {Data: []byte("package users\n\ntype User struct{}\n")},
+		"internal/users/user_impl.go": {Data: []byte(`package users
+
+import "example.com/p1/api/users"
+
+type userRepositoryImpl struct{}
+
+func (r *userRepositoryImpl) Get() users.User {
+	return users.User{}
+}
+`)},
+		"internal/users/extra.go": {Data: []byte(`package users
+
+import u "example.com/p1/api/users"
+
+func (r *userRepositoryImpl) Old() u.User {
+	return u.User{}
+}
+`)},
+	}
+	repos := []*Repository{
+		{
+			Package:     "users",
+			PackagePath: "api/users",
+			Ident:       "UserRepository",
+			Profile:     newProfile("Repository"),
+			Methods: []*Method{
+				{Ident: "Get", Returns: Params{{Type: "User"}}},
+				{Ident: "List", Returns: Params{{Type: "[]User"}}},
+			},
+		},
+	}
+	impls, err := parseRepositoryImpls(context.Background(), fsys, "internal/users", repos)
+	require.NoError(err)
+	grouped := groupByImplFilename(impls)
+	require.Len(grouped, 2)
+
+	// The other file is generated first, so that an alias resolved for it
+	// would leak into the file implementing the repository.
+	extra, err := generateRepositoryImplsForFile(fsys, "internal/users/extra.go", grouped["extra.go"])
+	require.NoError(err)
+	require.Contains(extra, "// Deprecated: ")
+	require.NotContains(extra, "List")
+	got, err := generateRepositoryImplsForFile(fsys, "internal/users/user_impl.go", grouped["user_impl.go"])
+	require.NoError(err)
+	require.Contains(got, "func (r *userRepositoryImpl) List() []users.User {")
+	require.NotContains(got, "u.")
+	require.Equal("users", impls[0].Package)
+}
@@ -9,6 +9,7 @@ import (
 	"io/fs"
 	"log/slog"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -22,8 +23,16 @@ type (
 		PackagePath string
 		Filename    string
 		Ident       string
+		Profile     *Profile
+		TypeParams  Params
 		Methods     []*Method
+		Embeds      []string
 		Imports     []Import
+		Directives  Directives
+		Doc         []string
+		// ErrorsPackage is the name the package wrapping errors is imported
+		// under in the file methods are generated in, or "" for its name.
+		ErrorsPackage string
 	}
 	RepositoryImpl struct {
 		Repository
@@ -32,20 +41,51 @@ type (
 		ImplPackagePath string
 		ImplFilename    string
 		ImplMethods     []string
+		// ImplSignatures are the methods declared on the implementation.
+		ImplSignatures []*ImplMethod
+		// Drifted are the implemented methods whose signature differs from the API.
+		Drifted []*MethodDrift
+		// Orphaned are the exported implementation methods missing from the API.
+		Orphaned []*ImplMethod
+	}
+	// ImplMethod is a method declared on an implementation.
+	ImplMethod struct {
+		Ident    string
+		Params   Params
+		Returns  Params
+		Filename string
+		Line     int
+		// SignatureStart and SignatureEnd delimit the parameters and results in the file.
+		SignatureStart uint32
+		SignatureEnd   uint32
+		// Start and End delimit the declaration in the file. DocStart is the
+		// start of its doc comment, or Start if it has none.
+		DocStart uint32
+		Start    uint32
+		End      uint32
+		Doc      []string
+		// Imports are the imports of the file declaring the method.
+		Imports []Import
 	}
 	Import struct {
 		Name string
 		Path string
 	}
 	Method struct {
-		Ident   string
-		Params  Params
-		Returns Params
+		Ident      string
+		Params     Params
+		Returns    Params
+		Directives Directives
+		Doc        []string
 	}
 	Params []*Param
 	Param  struct {
-		Ident string
-		Type  string
+		Ident      string
+		Type       string
+		Directives Directives
+		// Role is the role of the parameter in generated methods if it isn't
+		// implied by Type, e.g. for an aliased import of context.
+		Role paramRole
 	}
 )
 
@@ -67,7 +107,7 @@ func parseRepositoriesForPackage(
 	packagePath string,
 	packageFiles []string,
 ) (repos []*Repository, err error) {
-	repos = []*Repository{}
+	ifaces := []*Repository{}
 	for _, filename := range packageFiles {
 		fullPath := path.Join(packagePath, filename)
 		file, err := fsys.Open(fullPath)
@@ -84,68 +124,63 @@ func parseRepositoriesForPackage(
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse file %s: %w", fullPath, err)
 		}
-		packageRepos, err := parseRepositories(src, tree)
+		fileIfaces, err := parseInterfaces(src, tree)
 		if err != nil {
 			return nil, fmt.Errorf("failed to extract repositories from file %s: %w", fullPath, err)
 		}
-		for _, repo := range packageRepos {
-			repo.Filename = filename
-			repo.PackagePath = packagePath
+		for _, iface := range fileIfaces {
+			iface.Filename = filename
+			iface.PackagePath = packagePath
 		}
-		repos = append(repos, packageRepos...)
+		ifaces = append(ifaces, fileIfaces...)
 	}
-	return repos, nil
+	return filterRepositories(flattenEmbeds(ifaces)), nil
 }
 
+// parseRepositories returns the repositories declared in a single file.
 func parseRepositories(src []byte, tree *sitter.Tree) (repos []*Repository, err error) {
-	dstFile, err := parser.ParseFile(
-		fset,
-		"",
-		src,
-		parser.ImportsOnly,
-	)
+	ifaces, err := parseInterfaces(src, tree)
 	if err != nil {
 		return nil, err
 	}
-	imports := make([]Import, len(dstFile.Imports))
-	for i, imp := range dstFile.Imports {
-		name := ""
-		if imp.Name != nil {
-			name = imp.Name.Name
+	return filterRepositories(flattenEmbeds(ifaces)), nil
+}
+
+func filterRepositories(ifaces []*Repository) []*Repository {
+	repos := []*Repository{}
+	for _, iface := range ifaces {
+		if iface.Directives.Has("skip") {
+			continue
 		}
-		path, _ := strconv.Unquote(imp.Path.Value)
-		imports[i] = Import{
-			Name: name,
-			Path: path,
+		if profile := matchProfile(iface.Ident); profile != nil {
+			iface.Profile = profile
+			repos = append(repos, iface)
 		}
 	}
-	defer func() {
-		for _, repo := range repos {
-			repo.Imports = imports
-		}
-	}()
+	return repos
+}
+
+// parseInterfaces returns every interface declared in a single file, regardless
+// of whether it is a repository, so that embedded interfaces can be resolved.
+func parseInterfaces(src []byte, tree *sitter.Tree) (ifaces []*Repository, err error) {
+	imports, err := parseImports(src)
+	if err != nil {
+		return nil, err
+	}
 
 	const (
 		PKG_CAPTURE = iota
 		CLASS_NAME_CAPTURE
-		METHOD_NAME_CAPTURE
-		PARAMS_CAPTURE
-		RESULT_CAPTURE
+		TYPE_PARAMS_CAPTURE
+		INTERFACE_CAPTURE
 	)
 	query, err := sitter.NewQuery([]byte(`
-(package_clause (package_identifier) @pkg) 
+(package_clause (package_identifier) @pkg)
 
 (type_spec
-  name: (type_identifier) @class_name (#match? @class_name "Repository$")
-  type: 
-   (interface_type
-     (method_elem
-       name: (field_identifier) @method_name
-       parameters: (parameter_list) @params
-       result: [
-        (parameter_list)
-        (type_identifier)
-       ]? @result)?))
+  name: (type_identifier) @class_name
+  type_parameters: (type_parameter_list)? @type_params
+  type: (interface_type) @interface)
     `), lang)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create query: %w", err)
@@ -153,63 +188,32 @@ func parseRepositories(src []byte, tree *sitter.Tree) (repos []*Repository, err
 	qc := sitter.NewQueryCursor()
 	qc.Exec(query, tree.RootNode())
 
-	// Get package name
-	m, ok := qc.NextMatch()
-	if !ok {
-		return nil, nil
-	}
-	m = qc.FilterPredicates(m, src)
-	if len(m.Captures) != 1 {
-		return nil, ErrNoPackage
-	}
-
-	pkg := m.Captures[0].Node.Content(src)
-	defer func() {
-		for _, repo := range repos {
-			repo.Package = pkg
-		}
-	}()
-	m, ok = qc.NextMatch()
-	if !ok {
-		return nil, nil
-	}
-	var curIdx, methodIdx int
-	repos = append(repos, &Repository{})
+	var pkg string
 	for {
+		m, ok := qc.NextMatch()
+		if !ok {
+			break
+		}
 		m = qc.FilterPredicates(m, src)
+		var iface *Repository
 		for _, c := range m.Captures {
-			repo := repos[curIdx]
 			switch c.Index {
+			case PKG_CAPTURE:
+				pkg = c.Node.Content(src)
 			case CLASS_NAME_CAPTURE:
-				name := c.Node.Content(src)
-				if repo.Ident == name {
+				iface = &Repository{Ident: c.Node.Content(src)}
+				iface.Directives, iface.Doc = parseDirectives(typeSpecComments(src, c.Node.Parent()))
+				ifaces = append(ifaces, iface)
+			case TYPE_PARAMS_CAPTURE:
+				if iface == nil {
 					continue
-				} else if repo.Ident != "" {
-					// We have a new repository, so append the previous one to the list.
-					methodIdx = 0
-					curIdx++
-					repos = append(repos, &Repository{Ident: name})
-				} else if repo.Ident == "" {
-					repo.Ident = name
 				}
-			case METHOD_NAME_CAPTURE:
-				var curMethod *Method
-				methodName := c.Node.Content(src)
-				if len(repo.Methods) == 0 {
-					curMethod = &Method{Ident: methodName}
-					repo.Methods = append(repo.Methods, curMethod)
-				} else {
-					curMethod = repo.Methods[methodIdx]
-				}
-				if curMethod.Ident != methodName {
-					methodIdx++
-					repo.Methods = append(repo.Methods, &Method{Ident: methodName})
+				iface.TypeParams = parseTypeParams(src, c.Node)
+			case INTERFACE_CAPTURE:
+				if iface == nil {
+					continue
 				}
-
-			case PARAMS_CAPTURE:
-				repo.Methods[methodIdx].Params = parseParams(c.Node.Content(src))
-			case RESULT_CAPTURE:
-				repo.Methods[methodIdx].Returns = parseParams(c.Node.Content(src))
+				parseInterfaceElems(src, c.Node, iface)
 			default:
 				slog.Error(
 					"unhandled",
@@ -218,61 +222,243 @@ func parseRepositories(src []byte, tree *sitter.Tree) (repos []*Repository, err
 				)
 			}
 		}
-		m, ok = qc.NextMatch()
-		if !ok {
-			break
+	}
+	if len(ifaces) > 0 && pkg == "" {
+		return nil, ErrNoPackage
+	}
+	for _, iface := range ifaces {
+		iface.Package = pkg
+		iface.Imports = imports
+		for _, method := range iface.Methods {
+			resolveRoles(method.Params, imports)
+			resolveRoles(method.Returns, imports)
 		}
 	}
-	return
+	return ifaces, nil
 }
 
-func parseParams(src string) []*Param {
-	if src == "" {
-		return nil
+// parseImports returns the imports declared in a single file.
+func parseImports(src []byte) ([]Import, error) {
+	dstFile, err := parser.ParseFile(
+		fset,
+		"",
+		src,
+		parser.ImportsOnly,
+	)
+	if err != nil {
+		return nil, err
 	}
-	if src[0] == '(' {
-		src = src[1 : len(src)-1]
+	imports := make([]Import, len(dstFile.Imports))
+	for i, imp := range dstFile.Imports {
+		name := ""
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		path, _ := strconv.Unquote(imp.Path.Value)
+		imports[i] = Import{
+			Name: name,
+			Path: path,
+		}
 	}
-	parts := strings.Split(strings.TrimSpace(src), ",")
-	if len(parts) == 0 || (len(parts) == 1 && parts[0] == "") {
-		return nil
+	return imports, nil
+}
+
+// parseInterfaceElems populates the methods and embedded interfaces of iface
+// from the elements of an interface_type node.
+func parseInterfaceElems(src []byte, node *sitter.Node, iface *Repository) {
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		elem := node.NamedChild(i)
+		switch elem.Type() {
+		case "method_elem":
+			method := &Method{
+				Ident: elem.ChildByFieldName("name").Content(src),
+			}
+			method.Directives, method.Doc = parseDirectives(leadingComments(src, elem))
+			if params := elem.ChildByFieldName("parameters"); params != nil {
+				method.Params = parseParams(src, params)
+			}
+			if result := elem.ChildByFieldName("result"); result != nil {
+				method.Returns = parseParams(src, result)
+			}
+			iface.Methods = append(iface.Methods, method)
+		case "type_elem":
+			if elem.NamedChildCount() != 1 {
+				continue
+			}
+			switch embedded := elem.NamedChild(0); embedded.Type() {
+			case "type_identifier", "qualified_type", "generic_type":
+				iface.Embeds = append(iface.Embeds, embedded.Content(src))
+			}
+		}
 	}
-	named := false
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if named || len(strings.Split(part, " ")) > 1 {
-			named = true
+}
+
+// parseTypeParams returns the type parameters declared by a type_parameter_list node.
+func parseTypeParams(src []byte, node *sitter.Node) (params Params) {
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		decl := node.NamedChild(i)
+		if decl.Type() != "type_parameter_declaration" {
+			continue
+		}
+		constraint := decl.ChildByFieldName("type").Content(src)
+		for j := 0; j < int(decl.NamedChildCount()); j++ {
+			if name := decl.NamedChild(j); name.Type() == "identifier" {
+				params = append(params, &Param{Ident: name.Content(src), Type: constraint})
+			}
 		}
 	}
-	if named {
-		return parseNamedParams(parts)
+	return params
+}
+
+// flattenEmbeds adds the methods of embedded interfaces to the interfaces
+// embedding them. Only interfaces declared in ifaces can be resolved; embedding
+// interfaces from other packages requires type information.
+func flattenEmbeds(ifaces []*Repository) []*Repository {
+	byIdent := make(map[string]*Repository, len(ifaces))
+	for _, iface := range ifaces {
+		byIdent[iface.Ident] = iface
 	}
-	params := make([]*Param, len(parts))
-	for i, part := range parts {
-		params[i] = &Param{Type: strings.TrimSpace(part)}
+	done := make(map[*Repository]bool, len(ifaces))
+	var flatten func(iface *Repository)
+	flatten = func(iface *Repository) {
+		if done[iface] {
+			return
+		}
+		// Mark before recursing so that (invalid) cyclic embeddings terminate.
+		done[iface] = true
+		for _, embed := range iface.Embeds {
+			base, typeArgs := parseTypeInstance(embed)
+			embedded, ok := byIdent[base]
+			if !ok {
+				slog.Warn(
+					"Unable to resolve embedded interface without type information, its methods won't be implemented",
+					slog.String("interface", iface.Ident),
+					slog.String("embedded", embed),
+				)
+				continue
+			}
+			flatten(embedded)
+			methods := embedded.Methods
+			if len(typeArgs) > 0 {
+				methods = instantiateMethods(methods, embedded.TypeParams, typeArgs)
+			}
+			iface.Methods = mergeMethods(iface.Methods, methods)
+			iface.Imports = mergeImports(iface.Imports, embedded.Imports)
+		}
 	}
-	return params
+	for _, iface := range ifaces {
+		flatten(iface)
+	}
+	return ifaces
 }
 
-func parseNamedParams(parts []string) []*Param {
-	params := make([]*Param, len(parts))
-	untypedFrom := -1
-	for i, part := range parts {
-		part = strings.TrimSpace(part)
-		ident, typ, hasType := strings.Cut(part, " ")
-		ident, typ = strings.TrimSpace(ident), strings.TrimSpace(typ)
-		param := &Param{Ident: ident}
-		params[i] = param
-		if hasType {
-			param.Type = typ
-			if untypedFrom != -1 {
-				for j := untypedFrom; j < i; j++ {
-					params[j].Type = typ
-				}
-				untypedFrom = -1
+// instantiateMethods returns copies of methods with every type parameter
+// substituted by its corresponding type argument.
+func instantiateMethods(methods []*Method, typeParams Params, typeArgs []string) []*Method {
+	subst := make(map[string]string, len(typeParams))
+	for i, param := range typeParams {
+		if i < len(typeArgs) {
+			subst[param.Ident] = typeArgs[i]
+		}
+	}
+	instantiate := func(params Params) Params {
+		if params == nil {
+			return nil
+		}
+		instantiated := make(Params, len(params))
+		for i, param := range params {
+			instantiated[i] = &Param{
+				Ident:      param.Ident,
+				Directives: param.Directives,
+				Role:       param.Role,
+				Type: rewriteTypeExpr(param.Type, func(ident string) string {
+					if arg, ok := subst[ident]; ok {
+						return arg
+					}
+					return ident
+				}),
 			}
-		} else if untypedFrom == -1 {
-			untypedFrom = i
+		}
+		return instantiated
+	}
+	instantiated := make([]*Method, len(methods))
+	for i, method := range methods {
+		instantiated[i] = &Method{
+			Ident:      method.Ident,
+			Params:     instantiate(method.Params),
+			Returns:    instantiate(method.Returns),
+			Directives: method.Directives,
+			Doc:        method.Doc,
+		}
+	}
+	return instantiated
+}
+
+// mergeMethods appends the methods in b that are not already in a.
+func mergeMethods(a, b []*Method) []*Method {
+	for _, method := range b {
+		found := false
+		for _, existing := range a {
+			if existing.Ident == method.Ident {
+				found = true
+				break
+			}
+		}
+		if !found {
+			a = append(a, method)
+		}
+	}
+	return a
+}
+
+// mergeImports appends the imports in b whose path is not already in a.
+func mergeImports(a, b []Import) []Import {
+	for _, imp := range b {
+		found := false
+		for _, existing := range a {
+			if existing.Path == imp.Path {
+				found = true
+				break
+			}
+		}
+		if !found {
+			a = append(a, imp)
+		}
+	}
+	return a
+}
+
+// parseParams returns the parameters declared by a parameter_list node. A result
+// that is a single unparenthesised type is returned as one unnamed parameter.
+func parseParams(src []byte, node *sitter.Node) Params {
+	if node.Type() != "parameter_list" {
+		return Params{{Type: node.Content(src)}}
+	}
+	var params Params
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		decl := node.NamedChild(i)
+		var prefix string
+		switch decl.Type() {
+		case "parameter_declaration":
+		case "variadic_parameter_declaration":
+			prefix = "..."
+		default:
+			// Comments between parameters.
+			continue
+		}
+		typ := prefix + decl.ChildByFieldName("type").Content(src)
+		directives, _ := parseDirectives(leadingComments(src, decl))
+		named := false
+		for j := 0; j < int(decl.ChildCount()); j++ {
+			child := decl.Child(j)
+			if decl.FieldNameForChild(j) != "name" {
+				continue
+			}
+			named = true
+			params = append(params, &Param{Ident: child.Content(src), Type: typ, Directives: directives})
+		}
+		if !named {
+			params = append(params, &Param{Type: typ, Directives: directives})
 		}
 	}
 	return params
@@ -288,10 +474,20 @@ func parseRepositoryImpls(
 		return nil, nil
 	}
 
-	defaultImplFilename := func(repo *RepositoryImpl) string {
-		return strings.ToLower(repo.Name()) + "_impl.go"
+	defaultImplFilename := func(repo *RepositoryImpl) (string, error) {
+		if filename := repo.Directives.Get("file"); filename != "" {
+			if path.Base(filename) != filename || path.Ext(filename) != ".go" {
+				return "", fmt.Errorf("invalid directive on %s: file: %q can't be used", repo.Ident, filename)
+			}
+			return filename, nil
+		}
+		name := strings.ToLower(repo.Name())
+		if variant := repo.Directives.Get("impl"); variant != "" {
+			name = strings.ToLower(variant) + "_" + name
+		}
+		return name + repo.profile().FileSuffix, nil
 	}
-	implPackageName := repos[0].Package + "impl"
+	implPackageName := repos[0].Package + config.PackageSuffix
 	impls := make([]*RepositoryImpl, len(repos))
 	for i, repo := range repos {
 		impls[i] = &RepositoryImpl{
@@ -305,7 +501,11 @@ func parseRepositoryImpls(
 			for _, repo := range impls {
 				repo.ImplPackage = implPackageName
 				repo.ImplPackagePath = implPackagePath
-				repo.ImplFilename = defaultImplFilename(repo)
+				filename, err := defaultImplFilename(repo)
+				if err != nil {
+					return nil, err
+				}
+				repo.ImplFilename = filename
 				repo.IsNew = true
 			}
 			return impls, nil
@@ -315,6 +515,7 @@ func parseRepositoryImpls(
 
 	implDeclsToFileMap := make(map[string]string)
 	repositoryToMethodMap := make(map[string][]string)
+	repositoryToSignatureMap := make(map[string][]*ImplMethod)
 	for _, d := range entries {
 		if d.IsDir() || !strings.HasSuffix(d.Name(), ".go") {
 			continue
@@ -348,6 +549,16 @@ func parseRepositoryImpls(
 		for rep, methods := range methods {
 			repositoryToMethodMap[rep] = append(repositoryToMethodMap[rep], methods...)
 		}
+		signatures, err := parseImplMethods(ctx, src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse methods in %s: %w", path, err)
+		}
+		for rep, methods := range signatures {
+			for _, method := range methods {
+				method.Filename = filename
+			}
+			repositoryToSignatureMap[rep] = append(repositoryToSignatureMap[rep], methods...)
+		}
 	}
 
 	for _, repo := range impls {
@@ -357,8 +568,15 @@ func parseRepositoryImpls(
 		if filename, ok := implDeclsToFileMap[implName]; ok {
 			repo.ImplFilename = filename
 			repo.ImplMethods = repositoryToMethodMap[implName]
+			repo.ImplSignatures = repositoryToSignatureMap[implName]
+			repo.Drifted = repo.driftedMethods()
+			repo.Orphaned = repo.orphanedMethods()
 		} else {
-			repo.ImplFilename = defaultImplFilename(repo)
+			filename, err := defaultImplFilename(repo)
+			if err != nil {
+				return nil, err
+			}
+			repo.ImplFilename = filename
 			repo.IsNew = true
 		}
 	}
@@ -379,16 +597,17 @@ func parseRepositoryImplFile(ctx context.Context, src []byte) (
 	)
 	repImpls = []string{}
 	methods = make(map[string][]string)
+	implSuffixes := strings.ReplaceAll(implSuffixPattern(), `\`, `\\`)
 	query, err := sitter.NewQuery([]byte(`
   (
     (package_clause (package_identifier) @pkg)
     (type_declaration 
       (type_spec
-          name: (type_identifier) @impl_name (#match? @impl_name "Impl$")))?
+          name: (type_identifier) @impl_name (#match? @impl_name "`+implSuffixes+`$")))?
     (method_declaration
         receiver: (parameter_list
           (parameter_declaration
-            type: (_) @impl_rec (#match? @impl_rec "Impl$")))
+            type: (_) @impl_rec (#match? @impl_rec "`+implSuffixes+`(\\[.*\\])?$")))
         name: (field_identifier) @impl_field)?
   )
 `), lang)
@@ -427,6 +646,7 @@ func parseRepositoryImplFile(ctx context.Context, src []byte) (
 				} else if rec[0] == '*' {
 					rec = rec[1:]
 				}
+				rec, _, _ = strings.Cut(rec, "[")
 				curRec = rec
 			case IMPL_FIELD_CAPTURE:
 				if curRec == "" {
@@ -461,3 +681,81 @@ func parseRepositoryImplFile(ctx context.Context, src []byte) (
 	}
 	return
 }
+
+// parseImplMethods returns the methods declared on implementations in a single
+// file, keyed by the receiver type.
+func parseImplMethods(ctx context.Context, src []byte) (map[string][]*ImplMethod, error) {
+	const (
+		REC_CAPTURE = iota
+		NAME_CAPTURE
+		PARAMS_CAPTURE
+		RESULT_CAPTURE
+		METHOD_CAPTURE
+	)
+	query, err := sitter.NewQuery([]byte(`
+(method_declaration
+  receiver: (parameter_list
+    (parameter_declaration type: (_) @rec))
+  name: (field_identifier) @name
+  parameters: (parameter_list) @params
+  result: (_)? @result) @method
+`), lang)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query: %w", err)
+	}
+	tree, err := tsparser.ParseCtx(ctx, nil, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file: %w", err)
+	}
+	imports, err := parseImports(src)
+	if err != nil {
+		return nil, err
+	}
+	implName := regexp.MustCompile(implSuffixPattern() + "$")
+	qc := sitter.NewQueryCursor()
+	qc.Exec(query, tree.RootNode())
+
+	methods := make(map[string][]*ImplMethod)
+	for {
+		m, ok := qc.NextMatch()
+		if !ok {
+			break
+		}
+		var (
+			rec    string
+			method = &ImplMethod{Imports: imports}
+		)
+		for _, c := range m.Captures {
+			switch c.Index {
+			case REC_CAPTURE:
+				rec = strings.TrimPrefix(c.Node.Content(src), "*")
+				rec, _, _ = strings.Cut(rec, "[")
+			case NAME_CAPTURE:
+				method.Ident = c.Node.Content(src)
+			case PARAMS_CAPTURE:
+				method.Params = parseParams(src, c.Node)
+				method.Line = int(c.Node.StartPoint().Row) + 1
+				method.SignatureStart = c.Node.StartByte()
+				method.SignatureEnd = c.Node.EndByte()
+			case RESULT_CAPTURE:
+				method.Returns = parseParams(src, c.Node)
+				method.SignatureEnd = c.Node.EndByte()
+			case METHOD_CAPTURE:
+				method.Start = c.Node.StartByte()
+				method.DocStart = method.Start
+				method.End = c.Node.EndByte()
+				for i, comment := range leadingCommentNodes(c.Node) {
+					if i == 0 {
+						method.DocStart = comment.StartByte()
+					}
+					method.Doc = append(method.Doc, comment.Content(src))
+				}
+			}
+		}
+		if !implName.MatchString(rec) {
+			continue
+		}
+		methods[rec] = append(methods[rec], method)
+	}
+	return methods, nil
+}
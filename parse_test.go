@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"testing/fstest"
 
@@ -238,6 +239,63 @@ func TestParseRepositories(t *testing.T) {
 				},
 			},
 		},
+		{
+			"parameters are parsed from the syntax tree",
+			`
+      package main
+
+      type Repository interface {
+        A(cb func(a, b int) error, m map[Key]struct{ A, B int }) (Pair[A, B], error)
+        B(
+          // The format string.
+          format string,
+          args ...any, // Trailing comment.
+        ) (n int, err error)
+        C(int, []string) (int, int)
+      }
+      `,
+			[]*Repository{
+				{
+					Package: "main",
+					Ident:   "Repository",
+					Methods: []*Method{
+						{
+							Ident: "A",
+							Params: []*Param{
+								{Ident: "cb", Type: "func(a, b int) error"},
+								{Ident: "m", Type: "map[Key]struct{ A, B int }"},
+							},
+							Returns: []*Param{
+								{Type: "Pair[A, B]"},
+								{Type: "error"},
+							},
+						},
+						{
+							Ident: "B",
+							Params: []*Param{
+								{Ident: "format", Type: "string"},
+								{Ident: "args", Type: "...any"},
+							},
+							Returns: []*Param{
+								{Ident: "n", Type: "int"},
+								{Ident: "err", Type: "error"},
+							},
+						},
+						{
+							Ident: "C",
+							Params: []*Param{
+								{Type: "int"},
+								{Type: "[]string"},
+							},
+							Returns: []*Param{
+								{Type: "int"},
+								{Type: "int"},
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			"multiple Repository with methods",
 			`
@@ -264,6 +322,238 @@ func TestParseRepositories(t *testing.T) {
 				},
 			},
 		},
+		{
+			"embedded interfaces in the same file are flattened",
+			`
+      package main
+
+      type CRUDRepository interface {
+        Get(id string) (string, error)
+        Delete(id string) error
+      }
+
+      type Banner interface { Ban(id string) error }
+
+      type UserRepository interface {
+        CRUDRepository
+        Banner
+        io.Closer
+        Delete(id string) error
+      }
+      `,
+			[]*Repository{
+				{
+					Package: "main",
+					Ident:   "CRUDRepository",
+					Methods: []*Method{
+						{
+							Ident:   "Get",
+							Params:  []*Param{{Ident: "id", Type: "string"}},
+							Returns: []*Param{{Type: "string"}, {Type: "error"}},
+						},
+						{
+							Ident:   "Delete",
+							Params:  []*Param{{Ident: "id", Type: "string"}},
+							Returns: []*Param{{Type: "error"}},
+						},
+					},
+				},
+				{
+					Package: "main",
+					Ident:   "UserRepository",
+					Methods: []*Method{
+						{
+							Ident:   "Delete",
+							Params:  []*Param{{Ident: "id", Type: "string"}},
+							Returns: []*Param{{Type: "error"}},
+						},
+						{
+							Ident:   "Get",
+							Params:  []*Param{{Ident: "id", Type: "string"}},
+							Returns: []*Param{{Type: "string"}, {Type: "error"}},
+						},
+						{
+							Ident:   "Ban",
+							Params:  []*Param{{Ident: "id", Type: "string"}},
+							Returns: []*Param{{Type: "error"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			"type parameters are parsed and instantiated embeddings are substituted",
+			`
+      package main
+
+      type StoreRepository[T any, K, V comparable] interface {
+        Get(id K) (T, error)
+        List(ids ...K) (map[K]V, error)
+      }
+
+      type UserRepository interface {
+        StoreRepository[User, string, []User]
+      }
+      `,
+			[]*Repository{
+				{
+					Package: "main",
+					Ident:   "StoreRepository",
+					TypeParams: Params{
+						{Ident: "T", Type: "any"},
+						{Ident: "K", Type: "comparable"},
+						{Ident: "V", Type: "comparable"},
+					},
+					Methods: []*Method{
+						{
+							Ident:   "Get",
+							Params:  []*Param{{Ident: "id", Type: "K"}},
+							Returns: []*Param{{Type: "T"}, {Type: "error"}},
+						},
+						{
+							Ident:   "List",
+							Params:  []*Param{{Ident: "ids", Type: "...K"}},
+							Returns: []*Param{{Type: "map[K]V"}, {Type: "error"}},
+						},
+					},
+				},
+				{
+					Package: "main",
+					Ident:   "UserRepository",
+					Methods: []*Method{
+						{
+							Ident:   "Get",
+							Params:  []*Param{{Ident: "id", Type: "string"}},
+							Returns: []*Param{{Type: "User"}, {Type: "error"}},
+						},
+						{
+							Ident:   "List",
+							Params:  []*Param{{Ident: "ids", Type: "...string"}},
+							Returns: []*Param{{Type: "map[string][]User"}, {Type: "error"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			"directives are parsed from leading comments",
+			`
+      package main
+
+      // UserRepository stores users.
+      //
+      //implgen:impl=postgres
+      //implgen:file=users_pg.go
+      type UserRepository interface {
+        A() // trailing comments are ignored
+        //implgen:notrace
+        B()
+
+        //implgen:skip
+        C()
+      }
+
+      type (
+        //implgen:notrace
+        ARepository interface {}
+        BRepository interface {}
+      )
+      `,
+			[]*Repository{
+				{
+					Package:    "main",
+					Ident:      "UserRepository",
+					Directives: Directives{"impl": "postgres", "file": "users_pg.go"},
+					Doc:        []string{"// UserRepository stores users."},
+					Methods: []*Method{
+						{Ident: "A"},
+						{Ident: "B", Directives: Directives{"notrace": ""}},
+						{Ident: "C", Directives: Directives{"skip": ""}},
+					},
+				},
+				{Package: "main", Ident: "ARepository", Directives: Directives{"notrace": ""}},
+				{Package: "main", Ident: "BRepository"},
+			},
+		},
+		{
+			"parameter directives are parsed from leading comments",
+			`
+      package main
+
+      type UserRepository interface {
+        Login(
+          ctx context.Context,
+          username string, // trailing comments are ignored
+          //implgen:redact
+          password, otp string,
+        ) (token string, err error)
+      }
+      `,
+			[]*Repository{
+				{
+					Package: "main",
+					Ident:   "UserRepository",
+					Methods: []*Method{
+						{
+							Ident: "Login",
+							Params: Params{
+								{Ident: "ctx", Type: "context.Context"},
+								{Ident: "username", Type: "string"},
+								{Ident: "password", Type: "string", Directives: Directives{"redact": ""}},
+								{Ident: "otp", Type: "string", Directives: Directives{"redact": ""}},
+							},
+							Returns: Params{{Ident: "token", Type: "string"}, {Ident: "err", Type: "error"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			"skipped repositories are excluded",
+			`
+      package main
+
+      //implgen:skip
+      type ARepository interface {}
+
+      // BRepository is not skipped.
+      type BRepository interface {}
+      `,
+			[]*Repository{
+				{Package: "main", Ident: "BRepository", Doc: []string{"// BRepository is not skipped."}},
+			},
+		},
+		{
+			"doc comments are parsed",
+			`
+      package main
+
+      // ARepository does things.
+      // It is documented.
+      type ARepository interface {
+        // A does a thing.
+        A()
+
+        // Not documentation.
+
+        B()
+        /* C does another thing. */
+        C()
+      }
+      `,
+			[]*Repository{
+				{
+					Package: "main",
+					Ident:   "ARepository",
+					Doc:     []string{"// ARepository does things.", "// It is documented."},
+					Methods: []*Method{
+						{Ident: "A", Doc: []string{"// A does a thing."}},
+						{Ident: "B"},
+						{Ident: "C", Doc: []string{"/* C does another thing. */"}},
+					},
+				},
+			},
+		},
 		{
 			"imports are parsed",
 			`
@@ -381,6 +671,23 @@ func TestParseRepositoryImplFile(t *testing.T) {
 				},
 			},
 		},
+		{
+			"parses generic receivers",
+			`
+      package main
+
+      type storeRepositoryImpl[T any, ID comparable] struct {}
+
+      func (i *storeRepositoryImpl[T, ID]) Get() {}
+      `,
+			expect{
+				"main",
+				[]string{"storeRepositoryImpl"},
+				map[string][]string{
+					"storeRepositoryImpl": {"Get"},
+				},
+			},
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			require := require.New(t)
@@ -432,6 +739,38 @@ func TestParseRepositoryImpls(t *testing.T) {
 				},
 			},
 		},
+		{
+			"impl and file directives name new impl files",
+			map[string]string{},
+			"api",
+			[]string{},
+			[]*Repository{
+				{
+					Package:    "api",
+					Filename:   "one.go",
+					Ident:      "UserRepository",
+					Directives: Directives{"impl": "postgres"},
+				},
+				{
+					Package:    "api",
+					Filename:   "one.go",
+					Ident:      "PostRepository",
+					Directives: Directives{"impl": "postgres", "file": "posts_pg.go"},
+				},
+			},
+			[]*RepositoryImpl{
+				{
+					IsNew:        true,
+					ImplPackage:  "apiimpl",
+					ImplFilename: "postgres_user_impl.go",
+				},
+				{
+					IsNew:        true,
+					ImplPackage:  "apiimpl",
+					ImplFilename: "posts_pg.go",
+				},
+			},
+		},
 		{
 			"multiple repositories across multiple files",
 			map[string]string{
@@ -584,6 +923,27 @@ func TestParseRepositoryImpls(t *testing.T) {
 	}
 }
 
+func TestParseRepositoryImplsRejectsInvalidFileDirective(t *testing.T) {
+	for _, filename := range []string{"../users.go", "pg/users.go", "/users.go", "users.txt", "users"} {
+		t.Run(filename, func(t *testing.T) {
+			_, err := parseRepositoryImpls(
+				context.Background(),
+				fstest.MapFS{},
+				"api",
+				[]*Repository{
+					{
+						Package:    "api",
+						Filename:   "one.go",
+						Ident:      "UserRepository",
+						Directives: Directives{"file": filename},
+					},
+				},
+			)
+			require.ErrorContains(t, err, "invalid directive on UserRepository")
+		})
+	}
+}
+
 func testRepositories(t *testing.T, expected, actual []*Repository) {
 	t.Helper()
 	require := require.New(t)
@@ -592,6 +952,9 @@ func testRepositories(t *testing.T, expected, actual []*Repository) {
 		expect := expected[i]
 		require.Equal(expect.Package, repo.Package)
 		require.Equal(expect.Ident, repo.Ident)
+		require.Equal(expect.TypeParams, repo.TypeParams)
+		require.Equal(expect.Directives, repo.Directives)
+		require.Equal(expect.Doc, repo.Doc)
 		require.Len(repo.Methods, len(expect.Methods))
 		for j, method := range repo.Methods {
 			require.Equal(expect.Methods[j], method)
@@ -615,3 +978,56 @@ func testRepositoryImpls(t *testing.T, expected, actual []*RepositoryImpl) {
 		}
 	}
 }
+
+func TestParseImplMethods(t *testing.T) {
+	src := `package internal
+
+import stdctx "context"
+
+type userRepositoryImpl[T any] struct{}
+
+func (r *userRepositoryImpl[T]) Get(ctx stdctx.Context, a, b int) (T, error) {
+	panic("")
+}
+
+// Close closes.
+//
+// Deprecated: removed from api.UserRepository.
+func (r userRepositoryImpl[T]) Close() {}
+
+func (h *helper) Get() {}
+`
+	require := require.New(t)
+	methods, err := parseImplMethods(context.Background(), []byte(src))
+	require.NoError(err)
+	require.Len(methods, 1)
+	require.Len(methods["userRepositoryImpl"], 2)
+	imports := []Import{{Name: "stdctx", Path: "context"}}
+
+	get := methods["userRepositoryImpl"][0]
+	require.Equal("Get", get.Ident)
+	require.Equal(Params{
+		{Ident: "ctx", Type: "stdctx.Context"},
+		{Ident: "a", Type: "int"},
+		{Ident: "b", Type: "int"},
+	}, get.Params)
+	require.Equal(Params{{Type: "T"}, {Type: "error"}}, get.Returns)
+	require.Equal(7, get.Line)
+	require.Equal(imports, get.Imports)
+	require.Nil(get.Doc)
+	require.Equal("(ctx stdctx.Context, a, b int) (T, error)", src[get.SignatureStart:get.SignatureEnd])
+	require.Equal(get.Start, get.DocStart)
+	require.True(strings.HasPrefix(src[get.Start:get.End], "func (r *userRepositoryImpl[T]) Get("))
+	require.True(strings.HasSuffix(src[get.Start:get.End], "panic(\"\")\n}"))
+
+	closer := methods["userRepositoryImpl"][1]
+	require.Equal("Close", closer.Ident)
+	require.Nil(closer.Params)
+	require.Nil(closer.Returns)
+	require.Equal(14, closer.Line)
+	require.Equal([]string{"// Close closes.", "//", "// Deprecated: removed from api.UserRepository."}, closer.Doc)
+	require.True(closer.deprecated())
+	require.Equal("()", src[closer.SignatureStart:closer.SignatureEnd])
+	require.Equal("func (r userRepositoryImpl[T]) Close() {}", src[closer.Start:closer.End])
+	require.True(strings.HasPrefix(src[closer.DocStart:closer.End], "// Close closes.\n"))
+}
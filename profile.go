@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Profile describes which API interfaces are implemented and how their
+// implementations are named.
+type Profile struct {
+	// Suffix is trimmed from interface identifiers to derive the name used
+	// to qualify declarations, e.g. UserRepository -> UserDependencies.
+	Suffix string
+	// Pattern matches the identifiers of the interfaces using this profile.
+	Pattern *regexp.Regexp
+	// ImplSuffix is appended to the (lowercased) interface identifier to name
+	// the implementation struct.
+	ImplSuffix string
+	// FileSuffix is appended to the lowercased name to name new implementation files.
+	FileSuffix string
+	// Dependencies and Options name the dependencies struct and options
+	// variable declared for each implementation.
+	Dependencies string
+	Options      string
+	// Registry names the variable in the stub file aggregating the options of
+	// every implementation using this profile.
+	Registry string
+	// ProviderSet names the Wire provider set of each implementation, and of
+	// the stub file in place of Registry.
+	ProviderSet string
+	// Templates names the template set used to generate implementations.
+	Templates string
+}
+
+var (
+	defaultProfile = &Profile{
+		Suffix:       "Repository",
+		Pattern:      regexp.MustCompile("Repository$"),
+		ImplSuffix:   "Impl",
+		FileSuffix:   "_impl.go",
+		Dependencies: "Dependencies",
+		Options:      "Options",
+		Registry:     "Repositories",
+		ProviderSet:  "ProviderSet",
+		Templates:    "default",
+	}
+	profiles = []*Profile{defaultProfile}
+)
+
+// newProfile returns a profile for interfaces ending in suffix, naming
+// declarations after the suffix so that they don't collide with those of
+// other profiles in the same package.
+func newProfile(suffix string) *Profile {
+	if suffix == defaultProfile.Suffix {
+		return defaultProfile
+	}
+	lower := strings.ToLower(suffix)
+	return &Profile{
+		Suffix:       suffix,
+		Pattern:      regexp.MustCompile(regexp.QuoteMeta(suffix) + "$"),
+		ImplSuffix:   "Impl",
+		FileSuffix:   "_" + lower + "_impl.go",
+		Dependencies: suffix + "Dependencies",
+		Options:      suffix + "Options",
+		Registry:     pluralize(suffix),
+		ProviderSet:  suffix + "ProviderSet",
+		Templates:    "default",
+	}
+}
+
+// ProfileConfig is a profile of the configuration file, either a SUFFIX or
+// SUFFIX=PATTERN string or a mapping overriding the names derived from the
+// suffix, e.g.
+//
+//	profiles:
+//	  - suffix: Gateway
+//	    implSuffix: Client
+//	    options: Module
+//	    registry: Clients
+//	    templates: gateway
+type ProfileConfig struct {
+	Suffix       string `yaml:"suffix"`
+	Pattern      string `yaml:"pattern"`
+	ImplSuffix   string `yaml:"implSuffix"`
+	FileSuffix   string `yaml:"fileSuffix"`
+	Dependencies string `yaml:"dependencies"`
+	Options      string `yaml:"options"`
+	Registry     string `yaml:"registry"`
+	ProviderSet  string `yaml:"providerSet"`
+	Templates    string `yaml:"templates"`
+}
+
+// UnmarshalYAML decodes a profile from a SUFFIX or SUFFIX=PATTERN string or a
+// mapping. The obsolete form of the interface is implemented so that unknown
+// fields of mappings are still rejected.
+func (c *ProfileConfig) UnmarshalYAML(unmarshal func(any) error) error {
+	var spec string
+	if err := unmarshal(&spec); err == nil {
+		*c = parseProfileConfig(spec)
+		return nil
+	}
+	type profileFields ProfileConfig
+	return unmarshal((*profileFields)(c))
+}
+
+func parseProfileConfig(spec string) ProfileConfig {
+	suffix, pattern, _ := strings.Cut(spec, "=")
+	return ProfileConfig{Suffix: suffix, Pattern: pattern}
+}
+
+func (c ProfileConfig) spec() string {
+	if c.Pattern == "" {
+		return c.Suffix
+	}
+	return c.Suffix + "=" + c.Pattern
+}
+
+// profile returns the profile configured by c.
+func (c ProfileConfig) profile() (*Profile, error) {
+	if c.Suffix == "" {
+		return nil, fmt.Errorf("invalid profile %q: missing suffix", c.spec())
+	}
+	base := newProfile(c.Suffix)
+	if c == (ProfileConfig{Suffix: c.Suffix}) {
+		return base, nil
+	}
+	// base may be the shared default profile, which is copied.
+	profile := *base
+	if c.Pattern != "" {
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile %q: %w", c.spec(), err)
+		}
+		profile.Pattern = re
+	}
+	for _, name := range []struct {
+		field string
+		value string
+		name  *string
+		valid func(string) bool
+	}{
+		{"implSuffix", c.ImplSuffix, &profile.ImplSuffix, func(s string) bool { return token.IsIdentifier("x" + s) }},
+		{"fileSuffix", c.FileSuffix, &profile.FileSuffix, func(s string) bool { return path.Base(s) == s && path.Ext(s) == ".go" }},
+		{"dependencies", c.Dependencies, &profile.Dependencies, isExportedIdentifier},
+		{"options", c.Options, &profile.Options, isExportedIdentifier},
+		{"registry", c.Registry, &profile.Registry, isExportedIdentifier},
+		{"providerSet", c.ProviderSet, &profile.ProviderSet, isExportedIdentifier},
+		{"templates", c.Templates, &profile.Templates, func(s string) bool { return path.Base(s) == s }},
+	} {
+		if name.value == "" {
+			continue
+		}
+		if !name.valid(name.value) {
+			return nil, fmt.Errorf("invalid profile %q: %s: %q can't be used", c.spec(), name.field, name.value)
+		}
+		*name.name = name.value
+	}
+	return &profile, nil
+}
+
+func isExportedIdentifier(s string) bool {
+	return token.IsIdentifier(s) && token.IsExported(s)
+}
+
+// parseProfile parses a profile from a SUFFIX or SUFFIX=PATTERN specification.
+func parseProfile(spec string) (*Profile, error) {
+	return parseProfileConfig(spec).profile()
+}
+
+// matchProfile returns the first profile matching ident, or nil if ident
+// should not be implemented.
+func matchProfile(ident string) *Profile {
+	for _, profile := range profiles {
+		if profile.Pattern.MatchString(ident) {
+			return profile
+		}
+	}
+	return nil
+}
+
+func pluralize(s string) string {
+	switch {
+	case strings.HasSuffix(s, "y") && len(s) > 1 && !strings.ContainsRune("aeiou", rune(s[len(s)-2])):
+		return s[:len(s)-1] + "ies"
+	case strings.HasSuffix(s, "s"), strings.HasSuffix(s, "x"):
+		return s + "es"
+	}
+	return s + "s"
+}
+
+// implSuffixPattern returns a pattern matching the implementation suffix of any profile.
+func implSuffixPattern() string {
+	suffixes := []string{}
+	seen := map[string]bool{}
+	for _, profile := range profiles {
+		if !seen[profile.ImplSuffix] {
+			seen[profile.ImplSuffix] = true
+			suffixes = append(suffixes, regexp.QuoteMeta(profile.ImplSuffix))
+		}
+	}
+	return "(" + strings.Join(suffixes, "|") + ")"
+}
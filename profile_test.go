@@ -0,0 +1,172 @@
+package main
+
+import (
+	"maps"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProfile(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		spec    string
+		idents  map[string]bool
+		expect  Profile
+		wantErr bool
+	}{
+		{
+			"Repository returns the default profile",
+			"Repository",
+			map[string]bool{"FooRepository": true, "FooService": false},
+			*defaultProfile,
+			false,
+		},
+		{
+			"suffix derives names from the suffix",
+			"Gateway",
+			map[string]bool{"PaymentGateway": true, "Gateways": false},
+			Profile{
+				Suffix:       "Gateway",
+				ImplSuffix:   "Impl",
+				FileSuffix:   "_gateway_impl.go",
+				Dependencies: "GatewayDependencies",
+				Options:      "GatewayOptions",
+				Registry:     "Gateways",
+				ProviderSet:  "GatewayProviderSet",
+				Templates:    "default",
+			},
+			false,
+		},
+		{
+			"pattern overrides the matched identifiers",
+			"Service=^[A-Z]\\w*(Service|Svc)$",
+			map[string]bool{"UserService": true, "UserSvc": true, "userService": false},
+			Profile{
+				Suffix:       "Service",
+				ImplSuffix:   "Impl",
+				FileSuffix:   "_service_impl.go",
+				Dependencies: "ServiceDependencies",
+				Options:      "ServiceOptions",
+				Registry:     "Services",
+				ProviderSet:  "ServiceProviderSet",
+				Templates:    "default",
+			},
+			false,
+		},
+		{
+			"missing suffix is an error",
+			"=Service$",
+			nil,
+			Profile{},
+			true,
+		},
+		{
+			"invalid pattern is an error",
+			"Service=(",
+			nil,
+			Profile{},
+			true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			got, err := parseProfile(test.spec)
+			if test.wantErr {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+			for ident, match := range test.idents {
+				require.Equal(match, got.Pattern.MatchString(ident), ident)
+			}
+			// got may be the shared default profile, so a copy is compared.
+			profile := *got
+			profile.Pattern, test.expect.Pattern = nil, nil
+			require.Equal(test.expect, profile)
+		})
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	require := require.New(t)
+	require.Equal("Repositories", pluralize("Repository"))
+	require.Equal("Gateways", pluralize("Gateway"))
+	require.Equal("Services", pluralize("Service"))
+	require.Equal("Aliases", pluralize("Alias"))
+}
+
+func TestProfileConfig(t *testing.T) {
+	require := require.New(t)
+	profile, err := ProfileConfig{
+		Suffix:       "Gateway",
+		ImplSuffix:   "Client",
+		FileSuffix:   "_client.go",
+		Dependencies: "Deps",
+		Options:      "Module",
+		Registry:     "Clients",
+		ProviderSet:  "Set",
+		Templates:    "gateway",
+	}.profile()
+	require.NoError(err)
+	require.True(profile.Pattern.MatchString("PaymentGateway"))
+	profile.Pattern = nil
+	require.Equal(Profile{
+		Suffix:       "Gateway",
+		ImplSuffix:   "Client",
+		FileSuffix:   "_client.go",
+		Dependencies: "Deps",
+		Options:      "Module",
+		Registry:     "Clients",
+		ProviderSet:  "Set",
+		Templates:    "gateway",
+	}, *profile)
+
+	// Overriding the default profile doesn't modify it.
+	profile, err = ProfileConfig{Suffix: "Repository", Registry: "Stores"}.profile()
+	require.NoError(err)
+	require.Equal("Stores", profile.Registry)
+	require.Equal("Repositories", defaultProfile.Registry)
+
+	_, err = ProfileConfig{Suffix: "Gateway", FileSuffix: "client/impl.go"}.profile()
+	require.EqualError(err, `invalid profile "Gateway": fileSuffix: "client/impl.go" can't be used`)
+}
+
+func TestGenerateWithProfileConfig(t *testing.T) {
+	require := require.New(t)
+	defer func(original map[string]templateSet) { templateSets = original }(maps.Clone(templateSets))
+	require.NoError(loadTemplateOverrides(fstest.MapFS{
+		".implgen/templates/gateway/impl.tmpl": &fstest.MapFile{
+			Data: []byte(`
+var {{ .Repository.OptionsName }} = fx.Provide({{ .Repository.ConstructorName }})
+
+func {{ .Repository.ConstructorName }}(deps {{ .Repository.DependenciesName }}) {{ .Repository.Package }}.{{ .Repository.Ident }} {
+	return &{{ .Repository.ImplName }}{deps}
+}
+`),
+		},
+	}))
+	profile, err := ProfileConfig{
+		Suffix:       "Gateway",
+		ImplSuffix:   "Client",
+		Dependencies: "Deps",
+		Options:      "Module",
+		Templates:    "gateway",
+	}.profile()
+	require.NoError(err)
+
+	got, err := generateRepositoryImpl(Repository{
+		Package: "payments",
+		Ident:   "PaymentGateway",
+		Profile: profile,
+	})
+	require.NoError(err)
+	require.Equal(`
+var PaymentModule = fx.Provide(NewPaymentGateway)
+
+func NewPaymentGateway(deps PaymentDeps) payments.PaymentGateway {
+	return &paymentGatewayClient{deps}
+}
+`, got)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+)
+
+// paramRole is the role of a parameter or result in generated methods, which
+// start spans from contexts and wrap returned errors.
+type paramRole int
+
+const (
+	// roleImplied is the role implied by the type expression of a parameter,
+	// see Param.role.
+	roleImplied paramRole = iota
+	roleNone
+	// roleContext is context.Context, reassigned with the context of the span.
+	roleContext
+	// roleContextLike implements context.Context, such as an application
+	// specific context, and is passed to the span without being reassigned.
+	roleContextLike
+	// roleError is error, which can be wrapped.
+	roleError
+	// roleErrorLike is an interface implementing error, which is recorded on
+	// spans but can't be wrapped without changing its type.
+	roleErrorLike
+)
+
+// role returns the role of the parameter. Unless it was resolved from the
+// imports or types of the API, context.Context and error are recognised by
+// their type expression.
+func (p *Param) role() paramRole {
+	if p.Role != roleImplied {
+		return p.Role
+	}
+	return impliedRole(p.Type)
+}
+
+func impliedRole(typ string) paramRole {
+	switch typ {
+	case "context.Context":
+		return roleContext
+	case "error":
+		return roleError
+	}
+	return roleNone
+}
+
+// setRole records role on the parameter if it isn't implied by its type.
+func (p *Param) setRole(role paramRole) {
+	if role == impliedRole(p.Type) {
+		p.Role = roleImplied
+		return
+	}
+	p.Role = role
+}
+
+func (p Params) has(roles ...paramRole) bool {
+	for _, param := range p {
+		if slices.Contains(roles, param.role()) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitQualifiedType splits a type name qualified with its import path, e.g.
+// example.com/app.Context, into the import path and the name.
+func splitQualifiedType(typ string) (importPath, name string, ok bool) {
+	i := strings.LastIndex(typ, ".")
+	if i <= 0 || strings.HasSuffix(typ[:i], "/") || !token.IsIdentifier(typ[i+1:]) {
+		return "", "", false
+	}
+	return typ[:i], typ[i+1:], true
+}
+
+// registeredRole returns the role registered in the configuration for the type
+// name declared in importPath.
+func registeredRole(importPath, name string) paramRole {
+	typ := importPath + "." + name
+	switch {
+	case slices.Contains(config.ContextTypes, typ):
+		return roleContextLike
+	case slices.Contains(config.ErrorTypes, typ):
+		return roleErrorLike
+	}
+	return roleNone
+}
+
+// resolveRoles resolves the roles of params from the imports of the file
+// declaring them, without type information. Only context.Context, under any
+// import name, and the registered types referred to through an import are
+// recognised.
+func resolveRoles(params Params, imports []Import) {
+	paths := make(map[string]string, len(imports))
+	for _, imp := range imports {
+		name := imp.Name
+		if name == "" {
+			name = importName(imp.Path)
+		}
+		paths[name] = imp.Path
+	}
+	for _, param := range params {
+		qualifier, name, ok := strings.Cut(param.Type, ".")
+		if !ok || !token.IsIdentifier(qualifier) || !token.IsIdentifier(name) {
+			continue
+		}
+		// Types qualified by a package that isn't imported keep their implied role.
+		importPath, ok := paths[qualifier]
+		if !ok {
+			continue
+		}
+		role := registeredRole(importPath, name)
+		if importPath == "context" && name == "Context" {
+			role = roleContext
+		}
+		param.setRole(role)
+	}
+}
+
+// typeRoles resolves the roles of the types of a type-checked package.
+type typeRoles struct {
+	// context is context.Context, or nil if the package doesn't depend on it.
+	context types.Type
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+func newTypeRoles(pkg *types.Package) *typeRoles {
+	r := &typeRoles{}
+	seen := map[*types.Package]bool{}
+	queue := []*types.Package{pkg}
+	for len(queue) > 0 {
+		pkg, queue = queue[0], queue[1:]
+		if seen[pkg] {
+			continue
+		}
+		seen[pkg] = true
+		if pkg.Path() == "context" {
+			if obj, ok := pkg.Scope().Lookup("Context").(*types.TypeName); ok {
+				r.context = obj.Type()
+			}
+			break
+		}
+		queue = append(queue, pkg.Imports()...)
+	}
+	return r
+}
+
+// role returns the role of a parameter of type t. Besides the registered
+// types, any type implementing context.Context is context-like and any
+// interface implementing error is error-like.
+func (r *typeRoles) role(t types.Type) paramRole {
+	if types.Identical(t, errorType) {
+		return roleError
+	}
+	if r.context != nil && types.Identical(t, r.context) {
+		return roleContext
+	}
+	if named, ok := types.Unalias(t).(*types.Named); ok && named.Obj().Pkg() != nil {
+		if role := registeredRole(named.Obj().Pkg().Path(), named.Obj().Name()); role != roleNone {
+			return role
+		}
+	}
+	if r.context != nil && types.Implements(t, r.context.Underlying().(*types.Interface)) {
+		return roleContextLike
+	}
+	if types.IsInterface(t) && types.Implements(t, errorType.Underlying().(*types.Interface)) {
+		return roleErrorLike
+	}
+	return roleNone
+}
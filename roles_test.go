@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveRoles(t *testing.T) {
+	defer func(original *Config) { config = original }(config)
+	config = defaultConfig()
+	config.ContextTypes = []string{"example.com/app.Context"}
+	config.ErrorTypes = []string{"example.com/app/errs.Error"}
+
+	for _, test := range []struct {
+		name    string
+		typ     string
+		imports []Import
+		expect  paramRole
+	}{
+		{"context", "context.Context", []Import{{Path: "context"}}, roleContext},
+		{"aliased context", "stdctx.Context", []Import{{Name: "stdctx", Path: "context"}}, roleContext},
+		{"shadowed context", "context.Context", []Import{{Name: "context", Path: "example.com/context"}}, roleNone},
+		{"context without import", "context.Context", nil, roleContext},
+		{"error", "error", nil, roleError},
+		{"registered context", "app.Context", []Import{{Path: "example.com/app"}}, roleContextLike},
+		{"aliased registered error", "apperrs.Error", []Import{{Name: "apperrs", Path: "example.com/app/errs"}}, roleErrorLike},
+		{"unregistered type", "app.User", []Import{{Path: "example.com/app"}}, roleNone},
+		{"pointer", "*app.Context", []Import{{Path: "example.com/app"}}, roleNone},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			params := Params{{Ident: "p", Type: test.typ}}
+			resolveRoles(params, test.imports)
+			require.Equal(t, test.expect, params[0].role())
+		})
+	}
+}
+
+func TestTypeRoles(t *testing.T) {
+	require := require.New(t)
+	defer func(original *Config) { config = original }(config)
+	config = defaultConfig()
+	config.ContextTypes = []string{"example/api/users.Session"}
+
+	ctx := context.Background()
+	root := writeModule(t, map[string]string{
+		"go.mod": "module example\n\ngo 1.22\n",
+		"api/app/app.go": `
+    package app
+
+    import "context"
+
+    type Context interface {
+      context.Context
+      UserID() string
+    }
+
+    type Error interface {
+      error
+      Code() int
+    }
+    `,
+		"api/users/users.go": `
+    package users
+
+    import (
+      stdctx "context"
+      "example/api/app"
+    )
+
+    type Session struct{}
+
+    type NotFoundError struct{}
+
+    func (NotFoundError) Error() string { return "not found" }
+
+    type UserRepository interface {
+      Get(ctx stdctx.Context, id string) error
+      List(ctx app.Context) app.Error
+      Delete(session Session) *NotFoundError
+    }
+    `,
+	})
+	loaded, err := loadAPIPackages(ctx, root, "api")
+	require.NoError(err)
+	apiFiles, err := crawlAPI(os.DirFS(root), "api")
+	require.NoError(err)
+	repos, err := loadRepositoriesForPackage(ctx, os.DirFS(root), loaded, "api/users", apiFiles["api/users"])
+	require.NoError(err)
+	require.Len(repos, 1)
+
+	roles := func(params Params) (roles []paramRole) {
+		for _, param := range params {
+			roles = append(roles, param.role())
+		}
+		return roles
+	}
+	methods := repos[0].Methods
+	require.Equal([]paramRole{roleContext, roleNone}, roles(methods[0].Params))
+	require.Equal([]paramRole{roleError}, roles(methods[0].Returns))
+	require.Equal([]paramRole{roleContextLike}, roles(methods[1].Params))
+	require.Equal([]paramRole{roleErrorLike}, roles(methods[1].Returns))
+	require.Equal([]paramRole{roleContextLike}, roles(methods[2].Params))
+	require.Equal([]paramRole{roleNone}, roles(methods[2].Returns))
+
+	qualified := repos[0].qualifyMethod(methods[2])
+	require.Equal("users.Session", qualified.Params[0].Type)
+	require.Equal([]paramRole{roleContextLike}, roles(qualified.Params))
+}
+
+func TestGenerateMethodWithRoles(t *testing.T) {
+	require := require.New(t)
+	repository := Repository{
+		Package:     "users",
+		PackagePath: "api/users",
+		Ident:       "UserRepository",
+	}
+	got, err := generateMethodImpl(repository, Method{
+		Ident:   "Get",
+		Params:  Params{{Ident: "c", Type: "app.Context", Role: roleContextLike}},
+		Returns: Params{{Type: "app.Error", Role: roleErrorLike}},
+	})
+	require.NoError(err)
+	require.Equal(`
+  func (r *userRepositoryImpl) Get(ctx app.Context) (err app.Error) {
+    spanCtx, span := otel.GetTracerProvider().Tracer("users").Start(ctx, "User.Get")
+    defer func() {
+      if err != nil {
+        span.SetStatus(codes.Error, "")
+        span.RecordError(err)
+      }
+      span.End()
+    }()
+    _ = spanCtx
+    panic("TODO: implement users.UserRepository.Get")
+  }
+`, got)
+}
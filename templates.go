@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// templateDir is the directory, relative to the root, whose .tmpl files
+// override the templates of the default template set by name, e.g.
+// .implgen/templates/method.tmpl overrides the method template. Each
+// subdirectory declares a template set named after it, overriding the
+// templates of the default template set, which profiles can select.
+const templateDir = ".implgen/templates"
+
+const defaultTemplateSet = "default"
+
+// Names of the templates of a template set.
+const (
+	templateHeader = "header"
+	templateImpl   = "impl"
+	templateMethod = "method"
+	templateStub   = "stub"
+)
+
+const generateFileHeaderTemplate = `// This file will be automatically regenerated based on the API. Any repository implementations
+// will be copied through when generating and new methods will be added to the end.
+package {{ .Package }}
+{{ imports .Imports }}`
+
+// HeaderTemplateData is the data of the header template, which starts new
+// implementation files.
+type HeaderTemplateData struct {
+	// Package is the name of the implementation package.
+	Package string
+	// Imports are the imports required by the generated declarations.
+	Imports []Import
+}
+
+// ImplTemplateData is the data of the impl template, which declares a new
+// implementation of an API interface.
+type ImplTemplateData struct {
+	Repository
+}
+
+// MethodTemplateData is the data of the method template, which declares a new
+// method on an implementation. The types of Method are qualified with the name
+// of the API package.
+type MethodTemplateData struct {
+	Repository
+	Method
+}
+
+// StubTemplateData is the data of the stub template, which aggregates the
+// implementations of every API package.
+type StubTemplateData struct {
+	// Package is the name of the package of the stub file.
+	Package string
+	Imports []Import
+	// Registries aggregate the implementations of each profile.
+	Registries []*Registry
+	// MockDirectives generate the mocks of each API package.
+	MockDirectives []MockDirective
+}
+
+// Registry aggregates the implementations of a profile in the stub file.
+type Registry struct {
+	Name string
+	// Dependencies names the dependencies of the profile's implementations.
+	Dependencies string
+	Repositories []*RepositoryImpl
+}
+
+// Module groups the implementations of a registry declared in the same
+// implementation package.
+type Module struct {
+	// Name is the path of the API package relative to the API directory.
+	Name         string
+	Repositories []*RepositoryImpl
+}
+
+// Modules groups the implementations of the registry by implementation package.
+func (r *Registry) Modules() []*Module {
+	var modules []*Module
+	byPackage := map[string]*Module{}
+	for _, repository := range r.Repositories {
+		module, ok := byPackage[repository.ImplPackagePath]
+		if !ok {
+			module = &Module{Name: relativeToAPI(repository.PackagePath)}
+			byPackage[repository.ImplPackagePath] = module
+			modules = append(modules, module)
+		}
+		module.Repositories = append(module.Repositories, repository)
+	}
+	return modules
+}
+
+// FieldName returns the name of the field holding repository in a struct
+// aggregating the registry, qualified with its implementation package if
+// another repository of the registry shares its name.
+func (r *Registry) FieldName(repository *RepositoryImpl) string {
+	name := upperFirst(repository.Variant()) + repository.Ident
+	for _, other := range r.Repositories {
+		if other != repository && upperFirst(other.Variant())+other.Ident == name {
+			return upperFirst(repository.ImplPackage) + name
+		}
+	}
+	return name
+}
+
+// MockDirective generates the mocks of the repositories of an API package.
+type MockDirective struct {
+	// Src is the API package and Dst the mocks file, relative to the stub file.
+	Src          string
+	Dst          string
+	ImplPackage  string
+	Repositories []string
+}
+
+// templateSet is a set of templates used to generate implementations.
+type templateSet struct {
+	Header string
+	Method string
+	Impl   string
+	Stub   string
+}
+
+// templateSets are the template sets keyed by name. The Impl and Stub
+// templates are left empty to use those of the configured dependency injection
+// framework.
+var templateSets = map[string]templateSet{
+	defaultTemplateSet: {
+		Header: generateFileHeaderTemplate,
+		Method: generateMethodTemplate,
+	},
+}
+
+func (r Repository) templates() (templateSet, error) {
+	name := r.profile().Templates
+	set, ok := templateSets[name]
+	if !ok {
+		return templateSet{}, fmt.Errorf("unknown template set %q", name)
+	}
+	return set.withFramework(), nil
+}
+
+// withFramework returns the template set with the templates it leaves empty
+// replaced by those of the configured dependency injection framework.
+func (s templateSet) withFramework() templateSet {
+	framework := config.diFramework()
+	if s.Impl == "" {
+		s.Impl = framework.Impl
+	}
+	if s.Stub == "" {
+		s.Stub = framework.Stub
+	}
+	return s
+}
+
+// templateFuncs are the functions available to every template.
+var templateFuncs = template.FuncMap{
+	// pad surrounds a non-empty string with spaces, or returns a single space.
+	"pad": func(s string) string {
+		if s == "" {
+			return " "
+		}
+		return " " + s + " "
+	},
+	// qualify qualifies the types declared in the API package of a repository
+	// with the package name, e.g. User -> users.User.
+	"qualify": func(r Repository, typ string) string {
+		return r.qualifyType(typ)
+	},
+	"lowerFirst": lowerFirst,
+	"upperFirst": upperFirst,
+	// imports renders an import declaration, or nothing if there are no imports.
+	"imports": func(imports []Import) string {
+		if len(imports) == 0 {
+			return ""
+		}
+		var b strings.Builder
+		b.WriteString("\nimport (\n")
+		for _, imp := range imports {
+			b.WriteString("\t")
+			if imp.Name != "" {
+				b.WriteString(imp.Name + " ")
+			}
+			b.WriteString(strconv.Quote(imp.Path) + "\n")
+		}
+		b.WriteString(")\n")
+		return b.String()
+	},
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func executeTemplate(name, text string, data any) (string, error) {
+	tmpl, err := parseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// loadTemplateOverrides replaces the templates of the default template set with
+// those found in templateDir, and loads the template sets of its
+// subdirectories.
+func loadTemplateOverrides(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, templateDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	set := templateSets[defaultTemplateSet]
+	if err := overrideTemplates(fsys, templateDir, entries, &set); err != nil {
+		return err
+	}
+	templateSets[defaultTemplateSet] = set
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if name == defaultTemplateSet {
+			return fmt.Errorf("%s: the default template set is overridden by the files of %s", path.Join(templateDir, name), templateDir)
+		}
+		dir := path.Join(templateDir, name)
+		setEntries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return err
+		}
+		named := set
+		if err := overrideTemplates(fsys, dir, setEntries, &named); err != nil {
+			return err
+		}
+		templateSets[name] = named
+	}
+	return nil
+}
+
+// overrideTemplates replaces the templates of set with the .tmpl files among
+// the entries of dir.
+func overrideTemplates(fsys fs.FS, dir string, entries []fs.DirEntry, set *templateSet) error {
+	overrides := map[string]*string{
+		templateHeader: &set.Header,
+		templateImpl:   &set.Impl,
+		templateMethod: &set.Method,
+		templateStub:   &set.Stub,
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".tmpl" {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".tmpl")
+		templatePath := path.Join(dir, entry.Name())
+		text, ok := overrides[name]
+		if !ok {
+			names := make([]string, 0, len(overrides))
+			for name := range overrides {
+				names = append(names, name+".tmpl")
+			}
+			sort.Strings(names)
+			return fmt.Errorf("%s: unknown template, expected one of: %s", templatePath, strings.Join(names, ", "))
+		}
+		data, err := readFile(fsys, templatePath)
+		if err != nil {
+			return err
+		}
+		if _, err := parseTemplate(name, string(data)); err != nil {
+			return fmt.Errorf("%s: %w", templatePath, err)
+		}
+		*text = string(data)
+	}
+	return nil
+}
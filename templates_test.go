@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTemplateOverrides(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		fsys      map[string]string
+		expect    string
+		expectErr string
+	}{
+		{
+			"no overrides",
+			map[string]string{},
+			`
+  func (r *userRepositoryImpl) Get(ctx context.Context) {
+    ctx, span := otel.GetTracerProvider().Tracer("users").Start(ctx, "User.Get")
+    defer span.End()
+    _ = ctx
+    panic("TODO: implement users.UserRepository.Get")
+  }
+`,
+			"",
+		},
+		{
+			"method override",
+			map[string]string{
+				".implgen/templates/method.tmpl": `
+func (r *{{ .Repository.ImplName }}) {{ .Method.Ident }}({{ .Method.Params.ParamsSrc }}){{ pad .Method.Returns.ReturnsSrc }}{
+	panic("{{ lowerFirst .Method.Ident }} returns {{ qualify .Repository "User" }}")
+}
+`,
+				".implgen/templates/README.md": `Ignored`,
+			},
+			`
+func (r *userRepositoryImpl) Get(ctx context.Context) {
+	panic("get returns users.User")
+}
+`,
+			"",
+		},
+		{
+			"unknown template",
+			map[string]string{
+				".implgen/templates/methods.tmpl": ``,
+			},
+			"",
+			".implgen/templates/methods.tmpl: unknown template, expected one of: header.tmpl, impl.tmpl, method.tmpl, stub.tmpl",
+		},
+		{
+			"invalid template",
+			map[string]string{
+				".implgen/templates/impl.tmpl": `{{ .Repository.Ident `,
+			},
+			"",
+			`.implgen/templates/impl.tmpl: failed to parse template: template: impl:1: unclosed action`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			defer func(original templateSet) {
+				templateSets[defaultTemplateSet] = original
+			}(templateSets[defaultTemplateSet])
+			fsys := fstest.MapFS{}
+			for name, content := range test.fsys {
+				fsys[name] = &fstest.MapFile{Data: []byte(content), Mode: 0644}
+			}
+			err := loadTemplateOverrides(fsys)
+			if test.expectErr != "" {
+				require.EqualError(err, test.expectErr)
+				return
+			}
+			require.NoError(err)
+			repository := Repository{
+				Package:     "users",
+				PackagePath: "api/users",
+				Ident:       "UserRepository",
+				Methods: []*Method{{
+					Ident:  "Get",
+					Params: Params{{Ident: "ctx", Type: "context.Context"}},
+				}},
+			}
+			got, err := generateMethodImpl(repository, *repository.Methods[0])
+			require.NoError(err)
+			require.Equal(test.expect, got)
+		})
+	}
+}
+
+func TestGenerateFileHeader(t *testing.T) {
+	require := require.New(t)
+	got, err := executeTemplate(templateHeader, generateFileHeaderTemplate, HeaderTemplateData{
+		Package: "usersimpl",
+		Imports: []Import{
+			{Path: "context"},
+			{Name: "usersapi", Path: "example/api/users"},
+		},
+	})
+	require.NoError(err)
+	require.Equal(`// This file will be automatically regenerated based on the API. Any repository implementations
+// will be copied through when generating and new methods will be added to the end.
+package usersimpl
+
+import (
+	"context"
+	usersapi "example/api/users"
+)
+`, got)
+
+	got, err = executeTemplate(templateHeader, generateFileHeaderTemplate, HeaderTemplateData{
+		Package: "usersimpl",
+	})
+	require.NoError(err)
+	require.Equal(`// This file will be automatically regenerated based on the API. Any repository implementations
+// will be copied through when generating and new methods will be added to the end.
+package usersimpl
+`, got)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// tracer is a strategy starting a span in generated methods accepting a
+// context.
+type tracer struct {
+	// Start returns the statement starting a span named name from ctx,
+	// assigning its context to ctxVar and declaring span. pkg is the name of
+	// the API package.
+	Start func(ctxVar, pkg, name string) string
+	// RecordError are the statements recording err on the span.
+	RecordError []string
+	// End returns the call ending the span, given whether err is in scope.
+	End     func(hasErr bool) string
+	Imports []Import
+	// SetAttributes returns the statements recording the supported attributes
+	// on the span, if any.
+	SetAttributes func(attrs []spanAttribute) []string
+	// AttributeImports are the imports required by SetAttributes.
+	AttributeImports []Import
+}
+
+// spanAttribute is a parameter or named result recorded on a span.
+type spanAttribute struct {
+	Key string
+	// Value is an expression of type Type, one of the basic types string,
+	// bool, int, int64 or float64 or a slice of those.
+	Value string
+	Type  string
+}
+
+// newSpanAttribute returns the attribute recording param, converting integer
+// and float types to int64 and float64. It reports false if the type of param
+// can't be recorded.
+func newSpanAttribute(param *Param) (spanAttribute, bool) {
+	typ := param.Type
+	if elem, ok := strings.CutPrefix(typ, "..."); ok {
+		typ = "[]" + elem
+	}
+	attr := spanAttribute{Key: param.Ident, Value: param.Ident, Type: typ}
+	switch typ {
+	case "string", "bool", "int", "int64", "float64",
+		"[]string", "[]bool", "[]int", "[]int64", "[]float64":
+	case "int8", "int16", "int32", "rune", "uint8", "byte", "uint16", "uint32":
+		attr.Type = "int64"
+		attr.Value = "int64(" + param.Ident + ")"
+	case "float32":
+		attr.Type = "float64"
+		attr.Value = "float64(" + param.Ident + ")"
+	default:
+		return spanAttribute{}, false
+	}
+	return attr, true
+}
+
+// attributeCall returns the statements calling fn with one argument per
+// supported attribute, built with the constructors keyed by attribute type.
+func attributeCall(fn string, constructors map[string]string, attrs []spanAttribute) []string {
+	var args []string
+	for _, attr := range attrs {
+		value := attr.Value
+		constructor, ok := constructors[attr.Type]
+		if !ok && attr.Type == "int" {
+			// Backends without an int constructor record ints as int64.
+			constructor, ok = constructors["int64"]
+			value = "int64(" + value + ")"
+		}
+		if !ok {
+			continue
+		}
+		args = append(args, "\t"+constructor+"("+strconv.Quote(attr.Key)+", "+value+"),")
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return slices.Concat([]string{fn + "("}, args, []string{")"})
+}
+
+var tracers = map[string]tracer{
+	tracingOtel: {
+		Start: func(ctxVar, pkg, name string) string {
+			return ctxVar + ", span := otel.GetTracerProvider().Tracer(" + strconv.Quote(pkg) + ").Start(ctx, " + strconv.Quote(name) + ")"
+		},
+		RecordError: []string{
+			`span.SetStatus(codes.Error, "")`,
+			"span.RecordError(err)",
+		},
+		End: func(bool) string {
+			return "span.End()"
+		},
+		Imports: []Import{
+			{Path: "go.opentelemetry.io/otel"},
+			{Path: "go.opentelemetry.io/otel/codes"},
+		},
+		SetAttributes: func(attrs []spanAttribute) []string {
+			return attributeCall("span.SetAttributes", map[string]string{
+				"string":    "attribute.String",
+				"bool":      "attribute.Bool",
+				"int":       "attribute.Int",
+				"int64":     "attribute.Int64",
+				"float64":   "attribute.Float64",
+				"[]string":  "attribute.StringSlice",
+				"[]bool":    "attribute.BoolSlice",
+				"[]int":     "attribute.IntSlice",
+				"[]int64":   "attribute.Int64Slice",
+				"[]float64": "attribute.Float64Slice",
+			}, attrs)
+		},
+		AttributeImports: []Import{{Path: "go.opentelemetry.io/otel/attribute"}},
+	},
+	tracingOpenCensus: {
+		Start: func(ctxVar, pkg, name string) string {
+			return ctxVar + ", span := trace.StartSpan(ctx, " + strconv.Quote(pkg+"."+name) + ")"
+		},
+		RecordError: []string{
+			"span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})",
+		},
+		End: func(bool) string {
+			return "span.End()"
+		},
+		Imports: []Import{{Path: "go.opencensus.io/trace"}},
+		// OpenCensus doesn't support slice attributes.
+		SetAttributes: func(attrs []spanAttribute) []string {
+			return attributeCall("span.AddAttributes", map[string]string{
+				"string":  "trace.StringAttribute",
+				"bool":    "trace.BoolAttribute",
+				"int64":   "trace.Int64Attribute",
+				"float64": "trace.Float64Attribute",
+			}, attrs)
+		},
+	},
+	tracingDatadog: {
+		Start: func(ctxVar, pkg, name string) string {
+			return "span, " + ctxVar + " := tracer.StartSpanFromContext(ctx, " + strconv.Quote(pkg+"."+name) + ")"
+		},
+		// Errors are recorded when finishing the span.
+		End: func(hasErr bool) string {
+			if hasErr {
+				return "span.Finish(tracer.WithError(err))"
+			}
+			return "span.Finish()"
+		},
+		Imports: []Import{{Path: "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"}},
+		SetAttributes: func(attrs []spanAttribute) (stmts []string) {
+			for _, attr := range attrs {
+				stmts = append(stmts, "span.SetTag("+strconv.Quote(attr.Key)+", "+attr.Value+")")
+			}
+			return stmts
+		},
+	},
+	tracingNone: {},
+}
+
+func (r Repository) tracer() tracer {
+	return tracers[config.forPackage(r.PackagePath).Tracing]
+}
+
+// StartSpan returns the statement starting the span of method.
+func (r Repository) StartSpan(method Method) string {
+	return r.tracer().Start(r.SpanContext(method), r.Package, r.Name()+"."+method.Ident)
+}
+
+// SpanContext returns the variable holding the context of the span of method.
+// A context-like parameter can't hold the context.Context returned by the
+// tracer, so a new variable is declared instead of reassigning ctx.
+func (r Repository) SpanContext(method Method) string {
+	if method.Params.has(roleContextLike) {
+		return "spanCtx"
+	}
+	return "ctx"
+}
+
+// RecordSpanError returns the statements recording a returned error on the span.
+func (r Repository) RecordSpanError() []string {
+	return r.tracer().RecordError
+}
+
+// EndSpan returns the call ending the span of method.
+func (r Repository) EndSpan(method Method) string {
+	return r.tracer().End(method.Returns.HasError())
+}
+
+// SpanParamAttributes returns the statements recording the parameters of method
+// on its span.
+func (r Repository) SpanParamAttributes(method Method) []string {
+	method.Params.Qualify()
+	return r.spanAttributes(method, method.Params)
+}
+
+// SpanResultAttributes returns the statements recording the named results of
+// method on its span, deferred until it returns.
+func (r Repository) SpanResultAttributes(method Method) []string {
+	method.Returns.Qualify()
+	return r.spanAttributes(method, method.Returns)
+}
+
+// spanAttributes returns the statements recording params on the span. Unnamed
+// and redacted parameters are skipped, which are those with a redact directive
+// or listed by the redact directive of the method. A bare redact directive on
+// the method redacts every parameter and result.
+func (r Repository) spanAttributes(method Method, params Params) []string {
+	setAttributes := r.tracer().SetAttributes
+	if setAttributes == nil {
+		return nil
+	}
+	redacted := map[string]bool{}
+	if method.Directives.Has("redact") {
+		names := method.Directives.Get("redact")
+		if names == "" {
+			return nil
+		}
+		for _, name := range strings.Split(names, ",") {
+			redacted[strings.TrimSpace(name)] = true
+		}
+	}
+	var attrs []spanAttribute
+	for _, param := range params {
+		if param.Ident == "" || param.Ident == "_" || redacted[param.Ident] || param.Directives.Has("redact") {
+			continue
+		}
+		if attr, ok := newSpanAttribute(param); ok {
+			attrs = append(attrs, attr)
+		}
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+	return setAttributes(attrs)
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracers(t *testing.T) {
+	repository := Repository{
+		Package:     "users",
+		PackagePath: "api/users",
+		Ident:       "UserRepository",
+	}
+	get := Method{
+		Ident:   "Get",
+		Params:  Params{{Ident: "ctx", Type: "context.Context"}},
+		Returns: Params{{Type: "*users.User"}, {Type: "error"}},
+	}
+	delete := Method{
+		Ident:  "Delete",
+		Params: Params{{Ident: "ctx", Type: "context.Context"}},
+	}
+	for _, test := range []struct {
+		name          string
+		tracing       string
+		errors        string
+		expectImports []Import
+		expectGet     string
+		expectDelete  string
+	}{
+		{
+			"otel",
+			tracingOtel,
+			errorsEris,
+			[]Import{{Path: "go.opentelemetry.io/otel"}, {Path: "go.opentelemetry.io/otel/codes"}},
+			`
+  func (r *userRepositoryImpl) Get(ctx context.Context) (_ *users.User, err error) {
+    ctx, span := otel.GetTracerProvider().Tracer("users").Start(ctx, "User.Get")
+    defer func() {
+      if err != nil {
+        err = eris.Wrap(err, "users.UserRepository.Get")
+        span.SetStatus(codes.Error, "")
+        span.RecordError(err)
+      }
+      span.End()
+    }()
+    _ = ctx
+    panic("TODO: implement users.UserRepository.Get")
+  }
+`,
+			`
+  func (r *userRepositoryImpl) Delete(ctx context.Context) {
+    ctx, span := otel.GetTracerProvider().Tracer("users").Start(ctx, "User.Delete")
+    defer span.End()
+    _ = ctx
+    panic("TODO: implement users.UserRepository.Delete")
+  }
+`,
+		},
+		{
+			"opencensus",
+			tracingOpenCensus,
+			errorsEris,
+			[]Import{{Path: "go.opencensus.io/trace"}},
+			`
+  func (r *userRepositoryImpl) Get(ctx context.Context) (_ *users.User, err error) {
+    ctx, span := trace.StartSpan(ctx, "users.User.Get")
+    defer func() {
+      if err != nil {
+        err = eris.Wrap(err, "users.UserRepository.Get")
+        span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+      }
+      span.End()
+    }()
+    _ = ctx
+    panic("TODO: implement users.UserRepository.Get")
+  }
+`,
+			`
+  func (r *userRepositoryImpl) Delete(ctx context.Context) {
+    ctx, span := trace.StartSpan(ctx, "users.User.Delete")
+    defer span.End()
+    _ = ctx
+    panic("TODO: implement users.UserRepository.Delete")
+  }
+`,
+		},
+		{
+			"datadog",
+			tracingDatadog,
+			errorsEris,
+			[]Import{{Path: "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"}},
+			`
+  func (r *userRepositoryImpl) Get(ctx context.Context) (_ *users.User, err error) {
+    span, ctx := tracer.StartSpanFromContext(ctx, "users.User.Get")
+    defer func() {
+      if err != nil {
+        err = eris.Wrap(err, "users.UserRepository.Get")
+      }
+      span.Finish(tracer.WithError(err))
+    }()
+    _ = ctx
+    panic("TODO: implement users.UserRepository.Get")
+  }
+`,
+			`
+  func (r *userRepositoryImpl) Delete(ctx context.Context) {
+    span, ctx := tracer.StartSpanFromContext(ctx, "users.User.Delete")
+    defer span.Finish()
+    _ = ctx
+    panic("TODO: implement users.UserRepository.Delete")
+  }
+`,
+		},
+		{
+			"datadog without wrapping",
+			tracingDatadog,
+			errorsNone,
+			[]Import{{Path: "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"}},
+			`
+  func (r *userRepositoryImpl) Get(ctx context.Context) (_ *users.User, err error) {
+    span, ctx := tracer.StartSpanFromContext(ctx, "users.User.Get")
+    defer func() {
+      span.Finish(tracer.WithError(err))
+    }()
+    _ = ctx
+    panic("TODO: implement users.UserRepository.Get")
+  }
+`,
+			`
+  func (r *userRepositoryImpl) Delete(ctx context.Context) {
+    span, ctx := tracer.StartSpanFromContext(ctx, "users.User.Delete")
+    defer span.Finish()
+    _ = ctx
+    panic("TODO: implement users.UserRepository.Delete")
+  }
+`,
+		},
+		{
+			"none",
+			tracingNone,
+			errorsEris,
+			nil,
+			`
+  func (r *userRepositoryImpl) Get(ctx context.Context) (_ *users.User, err error) {
+    defer func() {
+      if err != nil {
+        err = eris.Wrap(err, "users.UserRepository.Get")
+      }
+    }()
+    panic("TODO: implement users.UserRepository.Get")
+  }
+`,
+			`
+  func (r *userRepositoryImpl) Delete(ctx context.Context) {
+    panic("TODO: implement users.UserRepository.Delete")
+  }
+`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			defer func(original *Config) { config = original }(config)
+			config = defaultConfig()
+			config.Tracing = test.tracing
+			config.Errors = test.errors
+
+			require.Equal(test.expectImports, repository.tracer().Imports)
+			require.Equal(test.tracing != tracingNone, repository.Traces(get))
+			got, err := generateMethodImpl(repository, get)
+			require.NoError(err)
+			require.Equal(test.expectGet, got)
+			got, err = generateMethodImpl(repository, delete)
+			require.NoError(err)
+			require.Equal(test.expectDelete, got)
+		})
+	}
+}
+
+func TestSpanAttributes(t *testing.T) {
+	repository := Repository{
+		Package:     "users",
+		PackagePath: "api/users",
+		Ident:       "UserRepository",
+	}
+	search := Method{
+		Ident: "Search",
+		Params: Params{
+			{Ident: "ctx", Type: "context.Context"},
+			{Ident: "query", Type: "string"},
+			{Ident: "limit", Type: "int"},
+			{Ident: "offset", Type: "uint32"},
+			{Ident: "score", Type: "float32"},
+			{Ident: "active", Type: "bool"},
+			{Ident: "filter", Type: "users.Filter"},
+			{Ident: "password", Type: "string", Directives: Directives{"redact": ""}},
+			{Ident: "tags", Type: "...string"},
+		},
+		Returns: Params{
+			{Ident: "users", Type: "[]*users.User"},
+			{Ident: "total", Type: "int64"},
+			{Ident: "err", Type: "error"},
+		},
+	}
+	for _, test := range []struct {
+		name          string
+		tracing       string
+		method        Method
+		expectParams  []string
+		expectResults []string
+	}{
+		{
+			"otel",
+			tracingOtel,
+			search,
+			[]string{
+				"span.SetAttributes(",
+				`	attribute.String("query", query),`,
+				`	attribute.Int("limit", limit),`,
+				`	attribute.Int64("offset", int64(offset)),`,
+				`	attribute.Float64("score", float64(score)),`,
+				`	attribute.Bool("active", active),`,
+				`	attribute.StringSlice("tags", tags),`,
+				")",
+			},
+			[]string{
+				"span.SetAttributes(",
+				`	attribute.Int64("total", total),`,
+				")",
+			},
+		},
+		{
+			"opencensus",
+			tracingOpenCensus,
+			search,
+			[]string{
+				"span.AddAttributes(",
+				`	trace.StringAttribute("query", query),`,
+				`	trace.Int64Attribute("limit", int64(limit)),`,
+				`	trace.Int64Attribute("offset", int64(offset)),`,
+				`	trace.Float64Attribute("score", float64(score)),`,
+				`	trace.BoolAttribute("active", active),`,
+				")",
+			},
+			[]string{
+				"span.AddAttributes(",
+				`	trace.Int64Attribute("total", total),`,
+				")",
+			},
+		},
+		{
+			"datadog",
+			tracingDatadog,
+			search,
+			[]string{
+				`span.SetTag("query", query)`,
+				`span.SetTag("limit", limit)`,
+				`span.SetTag("offset", int64(offset))`,
+				`span.SetTag("score", float64(score))`,
+				`span.SetTag("active", active)`,
+				`span.SetTag("tags", tags)`,
+			},
+			[]string{
+				`span.SetTag("total", total)`,
+			},
+		},
+		{
+			"method redact directive lists parameters",
+			tracingOtel,
+			Method{
+				Ident:      "Login",
+				Params:     Params{{Ident: "username", Type: "string"}, {Ident: "password", Type: "string"}},
+				Returns:    Params{{Ident: "token", Type: "string"}},
+				Directives: Directives{"redact": "password, token"},
+			},
+			[]string{
+				"span.SetAttributes(",
+				`	attribute.String("username", username),`,
+				")",
+			},
+			nil,
+		},
+		{
+			"bare method redact directive redacts everything",
+			tracingOtel,
+			Method{
+				Ident:      "Login",
+				Params:     Params{{Ident: "username", Type: "string"}},
+				Returns:    Params{{Ident: "token", Type: "string"}},
+				Directives: Directives{"redact": ""},
+			},
+			nil,
+			nil,
+		},
+		{
+			"unnamed parameters are skipped",
+			tracingOtel,
+			Method{
+				Ident:   "Get",
+				Params:  Params{{Type: "context.Context"}, {Type: "string"}},
+				Returns: Params{{Type: "string"}, {Type: "error"}},
+			},
+			nil,
+			nil,
+		},
+		{
+			"none",
+			tracingNone,
+			search,
+			nil,
+			nil,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			defer func(original *Config) { config = original }(config)
+			config = defaultConfig()
+			config.Tracing = test.tracing
+
+			require.Equal(test.expectParams, repository.SpanParamAttributes(test.method))
+			require.Equal(test.expectResults, repository.SpanResultAttributes(test.method))
+		})
+	}
+}
+
+func TestGenerateMethodWithSpanAttributes(t *testing.T) {
+	require := require.New(t)
+	repository := Repository{
+		Package:     "users",
+		PackagePath: "api/users",
+		Ident:       "UserRepository",
+	}
+	got, err := generateMethodImpl(repository, Method{
+		Ident:   "Count",
+		Params:  Params{{Ident: "ctx", Type: "context.Context"}, {Ident: "active", Type: "bool"}},
+		Returns: Params{{Ident: "total", Type: "int"}},
+	})
+	require.NoError(err)
+	require.Equal(`
+  func (r *userRepositoryImpl) Count(ctx context.Context, active bool) (total int) {
+    ctx, span := otel.GetTracerProvider().Tracer("users").Start(ctx, "User.Count")
+    span.SetAttributes(
+    	attribute.Bool("active", active),
+    )
+    defer func() {
+      span.SetAttributes(
+      	attribute.Int("total", total),
+      )
+      span.End()
+    }()
+    _ = ctx
+    panic("TODO: implement users.UserRepository.Count")
+  }
+`, got)
+}
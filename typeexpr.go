@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+)
+
+// rewriteTypeExpr calls rewrite for every unqualified identifier referring to a
+// type in the type expression typ, replacing the identifier with the result.
+// Identifiers naming parameters, fields and methods, as well as package-qualified
+// identifiers, are left untouched. If typ can't be parsed it is returned as is.
+func rewriteTypeExpr(typ string, rewrite func(ident string) string) string {
+	variadic := strings.HasPrefix(typ, "...")
+	expr, err := parser.ParseExpr(strings.TrimPrefix(typ, "..."))
+	if err != nil {
+		return typ
+	}
+	skip := make(map[*ast.Ident]bool)
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			for _, name := range n.Names {
+				skip[name] = true
+			}
+		case *ast.SelectorExpr:
+			// Qualified identifiers are already resolved, but their type
+			// arguments are not.
+			return false
+		}
+		return true
+	})
+	changed := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			return false
+		case *ast.Ident:
+			if skip[n] {
+				return true
+			}
+			if rewritten := rewrite(n.Name); rewritten != n.Name {
+				n.Name = rewritten
+				changed = true
+			}
+		}
+		return true
+	})
+	if !changed {
+		return typ
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, token.NewFileSet(), expr); err != nil {
+		return typ
+	}
+	if variadic {
+		return "..." + buf.String()
+	}
+	return buf.String()
+}
+
+// parseTypeInstance splits an instantiated generic type such as Store[User, string]
+// into its base type and type arguments.
+func parseTypeInstance(typ string) (base string, args []string) {
+	expr, err := parser.ParseExpr(typ)
+	if err != nil {
+		return typ, nil
+	}
+	var indices []ast.Expr
+	switch e := expr.(type) {
+	case *ast.IndexExpr:
+		expr, indices = e.X, []ast.Expr{e.Index}
+	case *ast.IndexListExpr:
+		expr, indices = e.X, e.Indices
+	default:
+		return typ, nil
+	}
+	for _, index := range indices {
+		args = append(args, typ[index.Pos()-1:index.End()-1])
+	}
+	return typ[expr.Pos()-1 : expr.End()-1], args
+}
+
+// resolveQualifiers formats the type expression typ, replacing the package
+// qualifier of every qualified identifier with the result of resolve. If typ
+// can't be parsed it is returned as is.
+func resolveQualifiers(typ string, resolve func(qualifier string) string) string {
+	variadic := strings.HasPrefix(typ, "...")
+	expr, err := parser.ParseExpr(strings.TrimPrefix(typ, "..."))
+	if err != nil {
+		return typ
+	}
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				x.Name = resolve(x.Name)
+			}
+		}
+		return true
+	})
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, token.NewFileSet(), expr); err != nil {
+		return typ
+	}
+	if variadic {
+		return "..." + buf.String()
+	}
+	return buf.String()
+}
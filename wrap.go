@@ -0,0 +1,96 @@
+package main
+
+import (
+	"go/ast"
+	"strconv"
+)
+
+// errorWrapper is a strategy wrapping the errors returned by generated methods
+// with the qualified name of the method.
+type errorWrapper struct {
+	// Wrap returns the statement wrapping err with msg, referring to the
+	// package of Import by pkg.
+	Wrap func(pkg, msg string) string
+	// Import is the package wrapping errors, imported as Alias if its name is
+	// taken in the file methods are generated in.
+	Import Import
+	Alias  string
+}
+
+var errorWrappers = map[string]errorWrapper{
+	errorsEris: {
+		Wrap: func(pkg, msg string) string {
+			return "err = " + pkg + ".Wrap(err, " + strconv.Quote(msg) + ")"
+		},
+		Import: Import{Path: "github.com/rotisserie/eris"},
+		Alias:  "goeris",
+	},
+	errorsFmt: {
+		Wrap: func(pkg, msg string) string {
+			return "err = " + pkg + ".Errorf(" + strconv.Quote(msg+": %w") + ", err)"
+		},
+		Import: Import{Path: "fmt"},
+		Alias:  "stdfmt",
+	},
+	errorsPkgErrors: {
+		Wrap: func(pkg, msg string) string {
+			return "err = " + pkg + ".Wrap(err, " + strconv.Quote(msg) + ")"
+		},
+		Import: Import{Path: "github.com/pkg/errors"},
+		Alias:  "pkgerrors",
+	},
+	errorsCockroach: {
+		Wrap: func(pkg, msg string) string {
+			return "err = " + pkg + ".Wrap(err, " + strconv.Quote(msg) + ")"
+		},
+		Import: Import{Path: "github.com/cockroachdb/errors"},
+		Alias:  "crdberrors",
+	},
+	errorsNone: {},
+}
+
+func (r Repository) errorWrapper() errorWrapper {
+	return errorWrappers[config.forPackage(r.PackagePath).Errors]
+}
+
+// importIn returns the import of the package wrapping errors in astFile, which
+// may be nil for new files, and the name it is referred to by. Existing imports
+// of the package are reused, and it is aliased if another package is imported
+// under its name.
+func (w errorWrapper) importIn(astFile *ast.File) (Import, string) {
+	name := importName(w.Import.Path)
+	if astFile == nil {
+		return w.Import, name
+	}
+	taken := false
+	for _, imp := range astFile.Imports {
+		importPath, _ := strconv.Unquote(imp.Path.Value)
+		importedAs := importName(importPath)
+		if imp.Name != nil {
+			importedAs = imp.Name.Name
+		}
+		if importPath == w.Import.Path && importedAs != "_" && importedAs != "." {
+			return w.Import, importedAs
+		}
+		taken = taken || importedAs == name
+	}
+	if taken {
+		return Import{Name: w.Alias, Path: w.Import.Path}, w.Alias
+	}
+	return w.Import, name
+}
+
+// WrapError returns the statement wrapping the error returned by method, or ""
+// if errors aren't wrapped. Error-like results are never wrapped as the wrapped
+// error couldn't be assigned to them.
+func (r Repository) WrapError(method Method) string {
+	wrapper := r.errorWrapper()
+	if wrapper.Wrap == nil || !method.Returns.has(roleError) {
+		return ""
+	}
+	pkg := r.ErrorsPackage
+	if pkg == "" {
+		pkg = importName(wrapper.Import.Path)
+	}
+	return wrapper.Wrap(pkg, r.QualifiedName()+"."+method.Ident)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapError(t *testing.T) {
+	repository := Repository{
+		Package:     "users",
+		PackagePath: "api/users",
+		Ident:       "UserRepository",
+	}
+	get := Method{
+		Ident:   "Get",
+		Params:  Params{{Ident: "id", Type: "string"}},
+		Returns: Params{{Type: "*users.User"}, {Type: "error"}},
+	}
+	for _, test := range []struct {
+		name         string
+		errors       string
+		tracing      string
+		expectWrap   string
+		expectImport Import
+		expectMethod string
+	}{
+		{
+			"eris",
+			errorsEris,
+			tracingNone,
+			`err = eris.Wrap(err, "users.UserRepository.Get")`,
+			Import{Path: "github.com/rotisserie/eris"},
+			`
+  func (r *userRepositoryImpl) Get(id string) (_ *users.User, err error) {
+    defer func() {
+      if err != nil {
+        err = eris.Wrap(err, "users.UserRepository.Get")
+      }
+    }()
+    panic("TODO: implement users.UserRepository.Get")
+  }
+`,
+		},
+		{
+			"fmt",
+			errorsFmt,
+			tracingNone,
+			`err = fmt.Errorf("users.UserRepository.Get: %w", err)`,
+			Import{Path: "fmt"},
+			`
+  func (r *userRepositoryImpl) Get(id string) (_ *users.User, err error) {
+    defer func() {
+      if err != nil {
+        err = fmt.Errorf("users.UserRepository.Get: %w", err)
+      }
+    }()
+    panic("TODO: implement users.UserRepository.Get")
+  }
+`,
+		},
+		{
+			"pkg/errors",
+			errorsPkgErrors,
+			tracingNone,
+			`err = errors.Wrap(err, "users.UserRepository.Get")`,
+			Import{Path: "github.com/pkg/errors"},
+			`
+  func (r *userRepositoryImpl) Get(id string) (_ *users.User, err error) {
+    defer func() {
+      if err != nil {
+        err = errors.Wrap(err, "users.UserRepository.Get")
+      }
+    }()
+    panic("TODO: implement users.UserRepository.Get")
+  }
+`,
+		},
+		{
+			"cockroachdb/errors",
+			errorsCockroach,
+			tracingNone,
+			`err = errors.Wrap(err, "users.UserRepository.Get")`,
+			Import{Path: "github.com/cockroachdb/errors"},
+			`
+  func (r *userRepositoryImpl) Get(id string) (_ *users.User, err error) {
+    defer func() {
+      if err != nil {
+        err = errors.Wrap(err, "users.UserRepository.Get")
+      }
+    }()
+    panic("TODO: implement users.UserRepository.Get")
+  }
+`,
+		},
+		{
+			"none",
+			errorsNone,
+			tracingNone,
+			``,
+			Import{},
+			`
+  func (r *userRepositoryImpl) Get(id string) (_ *users.User, err error) {
+    panic("TODO: implement users.UserRepository.Get")
+  }
+`,
+		},
+		{
+			"none still records errors on spans",
+			errorsNone,
+			tracingOtel,
+			``,
+			Import{},
+			`
+  func (r *userRepositoryImpl) Get(ctx context.Context, id string) (_ *users.User, err error) {
+    ctx, span := otel.GetTracerProvider().Tracer("users").Start(ctx, "User.Get")
+    span.SetAttributes(
+    	attribute.String("id", id),
+    )
+    defer func() {
+      if err != nil {
+        span.SetStatus(codes.Error, "")
+        span.RecordError(err)
+      }
+      span.End()
+    }()
+    _ = ctx
+    panic("TODO: implement users.UserRepository.Get")
+  }
+`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			defer func(original *Config) { config = original }(config)
+			config = defaultConfig()
+			config.Errors = test.errors
+			config.Tracing = test.tracing
+
+			method := get
+			if test.tracing != tracingNone {
+				method.Params = append(Params{{Ident: "ctx", Type: "context.Context"}}, method.Params...)
+			}
+			require.Equal(test.expectWrap, repository.WrapError(method))
+			require.Equal(test.expectImport, repository.errorWrapper().Import)
+			got, err := generateMethodImpl(repository, method)
+			require.NoError(err)
+			require.Equal(test.expectMethod, got)
+		})
+	}
+	require.Empty(t, Repository{}.WrapError(Method{Ident: "Close"}), "methods not returning errors are not wrapped")
+}
+
+func TestWrapErrorImportName(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		imports string
+		expect  string
+	}{
+		{
+			"package name taken by another import",
+			`import "errors"
+
+var errNotFound = errors.New("not found")
+`,
+			`import (
+	"errors"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+var errNotFound = errors.New("not found")
+
+func (r *repositoryImpl) Get(id string) (err error) {
+	defer func() {
+		if err != nil {
+			err = pkgerrors.Wrap(err, "api.Repository.Get")
+		}
+	}()
+	panic("TODO: implement api.Repository.Get")
+}
+`,
+		},
+		{
+			"existing import is reused",
+			`import perrors "github.com/pkg/errors"
+
+var errNotFound = perrors.New("not found")
+`,
+			`import perrors "github.com/pkg/errors"
+
+var errNotFound = perrors.New("not found")
+
+func (r *repositoryImpl) Get(id string) (err error) {
+	defer func() {
+		if err != nil {
+			err = perrors.Wrap(err, "api.Repository.Get")
+		}
+	}()
+	panic("TODO: implement api.Repository.Get")
+}
+`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			defer func(original *Config) { config = original }(config)
+			config = defaultConfig()
+			config.Errors = errorsPkgErrors
+			config.Tracing = tracingNone
+			fsys := fstest.MapFS{
+				"go.mod":          {Data: []byte("module example\n")},
+				"internal/one.go": {Data: []byte("package internal\n\n" + test.imports)},
+			}
+			got, err := generateRepositoryImplsForFile(fsys, "internal/one.go", []*RepositoryImpl{
+				{
+					Repository: Repository{
+						Package:     "api",
+						PackagePath: "api",
+						Ident:       "Repository",
+						Methods: []*Method{{
+							Ident:   "Get",
+							Params:  Params{{Ident: "id", Type: "string"}},
+							Returns: Params{{Type: "error"}},
+						}},
+					},
+					ImplPackage:  "internal",
+					ImplFilename: "one.go",
+					ImplMethods:  []string{},
+				},
+			})
+			require.NoError(err)
+			require.Equal("package internal\n\n"+test.expect, got)
+		})
+	}
+}